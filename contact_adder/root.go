@@ -32,35 +32,59 @@ func (ca *ContactAdder) AddContact(ctx context.Context, contact *helper.Contact)
 		surname = surname + " DUPLICATE"
 	}
 
-	_, err = ca.People.CreateContact(&people.Person{
+	person := &people.Person{
 		Names: []*people.Name{
 			{
 				GivenName:  contact.Name,
 				FamilyName: surname,
 			},
 		},
-		EmailAddresses: []*people.EmailAddress{
-			{
-				Value: contact.Email,
-			},
-		},
-		PhoneNumbers: []*people.PhoneNumber{
-			{
-				Value: contact.Phone,
-			},
-		},
 		Organizations: []*people.Organization{
 			{
-				Name: contact.Organization,
+				Name:       contact.Organization,
+				Title:      contact.Title,
+				Department: contact.Department,
 			},
 		},
-	}).Context(ctx).Do()
+	}
+	for _, email := range contact.AllEmails() {
+		person.EmailAddresses = append(person.EmailAddresses, &people.EmailAddress{
+			Value: email.Value,
+			Type:  email.Type,
+		})
+	}
+	for _, phone := range contact.AllPhones() {
+		person.PhoneNumbers = append(person.PhoneNumbers, &people.PhoneNumber{
+			Value: phone.Value,
+			Type:  phoneType(phone.Type),
+		})
+	}
+	if contact.Address != "" {
+		person.Addresses = []*people.Address{{FormattedValue: contact.Address, Type: helper.TypeWork}}
+	}
+	if contact.Website != "" {
+		person.Urls = append(person.Urls, &people.Url{Value: contact.Website, Type: helper.TypeWork})
+	}
+	if contact.LinkedIn != "" {
+		person.Urls = append(person.Urls, &people.Url{Value: contact.LinkedIn, Type: "profile"})
+	}
+	_, err = ca.People.CreateContact(person).Context(ctx).Do()
 	if err != nil {
 		return nil, err
 	}
 	return contact, nil
 }
 
+func phoneType(valueType string) string {
+	switch valueType {
+	case helper.TypeFax:
+		return "workFax"
+	case helper.TypeMobile, helper.TypeWork, helper.TypeHome:
+		return valueType
+	}
+	return helper.TypeOther
+}
+
 func (ca *ContactAdder) CheckExists(ctx context.Context, contact *helper.Contact) (bool, error) {
 	resp, err := ca.People.SearchContacts().Query(contact.Name + " " + contact.Surname).ReadMask("names").Context(ctx).Do()
 	if err != nil {
@@ -0,0 +1,204 @@
+package contact_generator
+
+import (
+	"MailContactUtilty/helper"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+type Extractor interface {
+	Generate(ctx context.Context, data MailData) (*helper.Contact, error)
+	Close() error
+}
+
+type ExtractorConfig struct {
+	Backend     string
+	Model       string
+	ApiKey      string
+	BaseUrl     string
+	Fallback    string
+	Timeout     time.Duration
+	ImageFilter ImageFilterConfig
+}
+
+const (
+	BackendGemini    = "gemini"
+	BackendOpenAI    = "openai"
+	BackendOllama    = "ollama"
+	BackendHeuristic = "heuristic"
+)
+
+const (
+	defaultTimeout      = 2 * time.Minute
+	maxErrorBodyBytes   = 4 << 10
+	documentTextPrompt  = "Text of an attached document:\n"
+	signatureImageLabel = "The following image is part of the mail signature:"
+	attachedImageLabel  = "The following image was attached to the mail:"
+)
+
+type StatusError struct {
+	Url    string
+	Status string
+	Code   int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned %s: %s", e.Url, e.Status, e.Body)
+}
+
+func (e *StatusError) HTTPCode() int {
+	return e.Code
+}
+
+type typedField struct {
+	name  string
+	types []string
+}
+
+var (
+	contactFields = []string{"name", "surname", "email", "phone", "organization", "title", "department", "address", "website", "linkedin"}
+	typedFields   = []typedField{
+		{name: "emails", types: []string{helper.TypeWork, helper.TypeHome, helper.TypeOther}},
+		{name: "phones", types: []string{helper.TypeMobile, helper.TypeWork, helper.TypeHome, helper.TypeFax, helper.TypeOther}},
+	}
+)
+
+type promptPart struct {
+	Text     string
+	Image    *ImageData
+	Document *DocumentData
+}
+
+func NewExtractor(ctx context.Context, config ExtractorConfig) (Extractor, error) {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	var extractor Extractor
+	var err error
+	switch config.Backend {
+	case BackendGemini, "":
+		extractor, err = NewContactGenerator(ctx, config)
+	case BackendOpenAI:
+		extractor, err = NewOpenAIGenerator(config)
+	case BackendOllama:
+		extractor, err = NewOllamaGenerator(config)
+	case BackendHeuristic:
+		return NewHeuristicExtractor(), nil
+	default:
+		return nil, fmt.Errorf("unknown extractor backend: %s", config.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch config.Fallback {
+	case "":
+		return extractor, nil
+	case BackendHeuristic:
+		return NewFallbackExtractor(extractor, NewHeuristicExtractor()), nil
+	default:
+		extractor.Close()
+		return nil, fmt.Errorf("unknown extractor fallback: %s", config.Fallback)
+	}
+}
+
+func buildPrompt(filter *ImageFilter, data MailData) []promptPart {
+	images := filter.Filter(PrepareImages(data.Images))
+	parts := make([]promptPart, 0, 2*len(images)+len(data.Documents)+4)
+	for i := range images {
+		switch images[i].Position {
+		case ImagePositionSignature:
+			parts = append(parts, promptPart{Text: signatureImageLabel})
+		case ImagePositionAttached:
+			parts = append(parts, promptPart{Text: attachedImageLabel})
+		}
+		parts = append(parts, promptPart{Image: &images[i]})
+	}
+	if data.Signature != "" {
+		parts = append(parts, promptPart{Text: "This is the most recent signature block of the mail, prefer its data over the rest of the mail:\n" + data.Signature})
+	}
+	parts = append(parts, promptPart{Text: "Extract the sender data, utilizing the data from the top of the mail, aswell as the footer, from this mail: \n" +
+		data.Text + "\n" +
+		"Be very sure of the data you extract, if data is missing, do not make it up, but return an empty string instead, if the email or phone is different between the top and the footer, return the email or phone from the footer, be sure to include the data if the mail contains it",
+	})
+	parts = append(parts, promptPart{Text: "If images or documents are present, use them to extract the data, if they are not clear, return an empty string instead of making up data"})
+	parts = append(parts, promptPart{Text: "List every email address and phone number of the contact with its type in emails and phones, and put the main ones in email and phone. Include the job title, department, postal address, website and LinkedIn profile if present"})
+	for i := range data.Documents {
+		parts = append(parts, promptPart{Document: &data.Documents[i]})
+	}
+	if data.Original != nil {
+		parts = append(parts, promptPart{Text: originalSenderPrompt(data.Forwarder, data.Original)})
+	}
+	return parts
+}
+
+func contactSchema() map[string]any {
+	properties := map[string]any{}
+	required := slices.Clone(contactFields)
+	for _, field := range contactFields {
+		properties[field] = map[string]any{"type": "string"}
+	}
+	for _, field := range typedFields {
+		properties[field.name] = map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"value": map[string]any{"type": "string"},
+					"type":  map[string]any{"type": "string", "enum": field.types},
+				},
+				"required":             []string{"value", "type"},
+				"additionalProperties": false,
+			},
+		}
+		required = append(required, field.name)
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func parseContact(content string) (*helper.Contact, error) {
+	var contact helper.Contact
+	if err := json.Unmarshal([]byte(content), &contact); err != nil {
+		return nil, fmt.Errorf("invalid contact response: %w", err)
+	}
+	return &contact, nil
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, request any, response any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		return &StatusError{Url: url, Status: resp.Status, Code: resp.StatusCode, Body: string(bytes.TrimSpace(msg))}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", url, err)
+	}
+	return nil
+}
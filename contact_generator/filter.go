@@ -0,0 +1,138 @@
+package contact_generator
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+type ImageFilterConfig struct {
+	MinWidth     int
+	MinHeight    int
+	MaxImages    int
+	HashDistance *int
+	KnownHashes  []string
+}
+
+type ImageFilter struct {
+	config       ImageFilterConfig
+	hashDistance int
+	knownHashes  []uint64
+}
+
+const (
+	defaultMinImageSize   = 32
+	defaultMaxImages      = 5
+	defaultHashDistance   = 6
+	hashWidth, hashHeight = 9, 8
+)
+
+// builtinIconHashes are difference hashes of the LinkedIn, X and Facebook
+// logos, rendered dark on light and light on dark at common signature sizes.
+var builtinIconHashes = []uint64{
+	0x6086060806088848, 0x60c686080688c848, // facebook
+	0x0609303060303136, 0x0619313060313336, 0x0609302060202122,
+	0x0060402329292900, 0x006020636b696900, 0x002060232b2b2b00, // linkedin
+	0x0080809094969600, 0x00c080d0d4d4d400,
+	0x02a4586828448a04, // x
+	0x21532614163a65c0, 0x2152261c141a2540,
+}
+
+func NewImageFilter(config ImageFilterConfig) (*ImageFilter, error) {
+	if config.MinWidth <= 0 {
+		config.MinWidth = defaultMinImageSize
+	}
+	if config.MinHeight <= 0 {
+		config.MinHeight = defaultMinImageSize
+	}
+	if config.MaxImages <= 0 {
+		config.MaxImages = defaultMaxImages
+	}
+	filter := &ImageFilter{
+		config:       config,
+		hashDistance: defaultHashDistance,
+		knownHashes:  slices.Clone(builtinIconHashes),
+	}
+	if config.HashDistance != nil {
+		if *config.HashDistance < 0 {
+			return nil, fmt.Errorf("invalid image hash distance: %d", *config.HashDistance)
+		}
+		filter.hashDistance = *config.HashDistance
+	}
+	for _, value := range config.KnownHashes {
+		hash, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(value), "0x"), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid known image hash %q: %w", value, err)
+		}
+		filter.knownHashes = append(filter.knownHashes, hash)
+	}
+	return filter, nil
+}
+
+func (f *ImageFilter) Filter(images []ImageData) []ImageData {
+	filtered := make([]ImageData, 0, len(images))
+	var seen []uint64
+	for i, img := range images {
+		decoded := img.decoded
+		if decoded == nil {
+			var err error
+			decoded, _, err = decodeImage(img.Data)
+			if err != nil {
+				log.Printf("Skipping image %d: unable to decode: %v", i, err)
+				continue
+			}
+		}
+		bounds := decoded.Bounds()
+		if bounds.Dx() < f.config.MinWidth || bounds.Dy() < f.config.MinHeight {
+			log.Printf("Skipping image %d: too small (%dx%d)", i, bounds.Dx(), bounds.Dy())
+			continue
+		}
+		hash := DHash(decoded)
+		if f.matches(f.knownHashes, hash) {
+			log.Printf("Skipping image %d: known icon (hash %016x)", i, hash)
+			continue
+		}
+		if f.matches(seen, hash) {
+			log.Printf("Skipping image %d: duplicate (hash %016x)", i, hash)
+			continue
+		}
+		if len(filtered) >= f.config.MaxImages {
+			log.Printf("Skipping image %d: limit of %d images per message reached", i, f.config.MaxImages)
+			continue
+		}
+		log.Printf("Using image %d (%dx%d, hash %016x)", i, bounds.Dx(), bounds.Dy(), hash)
+		seen = append(seen, hash)
+		filtered = append(filtered, img)
+	}
+	return filtered
+}
+
+func (f *ImageFilter) matches(hashes []uint64, hash uint64) bool {
+	for _, known := range hashes {
+		if bits.OnesCount64(known^hash) <= f.hashDistance {
+			return true
+		}
+	}
+	return false
+}
+
+func DHash(img image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, hashWidth, hashHeight))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+	var hash uint64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
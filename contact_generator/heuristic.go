@@ -0,0 +1,262 @@
+package contact_generator
+
+import (
+	"MailContactUtilty/helper"
+	"MailContactUtilty/signature_detector"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+type HeuristicExtractor struct{}
+
+type FallbackExtractor struct {
+	primary  Extractor
+	fallback Extractor
+}
+
+const minPhoneDigits = 9
+
+var (
+	dateRegexp             = regexp.MustCompile(`\d{1,4}[-./]\d{1,2}[-./]\d{1,4}`)
+	heuristicUrlRegexp     = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>|]+`)
+	heuristicCompanyRegexp = regexp.MustCompile(`(?i)(?:\bsp\.? ?z ?o\.? ?o\.?|\bs\.? ?a\.?$|\bs\.? ?k\.?$|\bsp\.? ?j\.?$|\binc\.?|\bltd\.?|\bllc\b|\bgmbh\b|\bcorp\.?|\bcorporation\b|\bcompany\b|\bgroup\b|\bag\b$|\bplc\b)`)
+	introductionRegexp     = regexp.MustCompile(`(?:[Mm]y name is|I am|I'm|[Tt]his is|[Nn]azywam się|[Jj]estem|[Zz] tej strony)\s+(\p{Lu}\p{Ll}+(?:[ \-]\p{Lu}\p{Ll}+){1,2})`)
+	freeMailDomains        = []string{"gmail.com", "googlemail.com", "outlook.com", "hotmail.com", "live.com", "msn.com", "yahoo.com", "icloud.com", "me.com", "proton.me", "protonmail.com", "aol.com", "gmx.com", "gmx.de", "wp.pl", "o2.pl", "onet.pl", "op.pl", "interia.pl", "poczta.fm", "tlen.pl", "vp.pl"}
+)
+
+func NewHeuristicExtractor() *HeuristicExtractor {
+	return &HeuristicExtractor{}
+}
+
+func (h *HeuristicExtractor) Generate(ctx context.Context, data MailData) (*helper.Contact, error) {
+	signature := data.Signature
+	if signature == "" {
+		if detected := signature_detector.Detect(data.Text); detected != nil {
+			signature = detected.Text
+		}
+	}
+	var documents []string
+	for _, document := range data.Documents {
+		if document.Text != "" {
+			documents = append(documents, document.Text)
+		}
+	}
+	sources := []string{signature, data.Text, strings.Join(documents, "\n")}
+
+	contact := &helper.Contact{}
+	if data.Original != nil {
+		contact.Name, contact.Surname = splitName(data.Original.Name)
+		contact.Email = data.Original.Email
+	}
+	if contact.Name == "" && contact.Surname == "" {
+		contact.Name, contact.Surname = splitName(signatureName(signature))
+	}
+	if contact.Name == "" && contact.Surname == "" {
+		if matches := introductionRegexp.FindStringSubmatch(data.Text); matches != nil {
+			contact.Name, contact.Surname = splitName(matches[1])
+		}
+	}
+	if contact.Email == "" {
+		contact.Email = firstEmail(sources, data.Forwarder)
+	}
+	contact.Phone = firstPhone(sources)
+	for _, line := range strings.Split(signature, "\n") {
+		line = strings.Trim(line, " \t|")
+		for _, phone := range phones(line) {
+			contact.Phones = append(contact.Phones, helper.TypedValue{Value: phone, Type: phoneType(line)})
+		}
+		for _, email := range signature_detector.EmailRegexp.FindAllString(line, -1) {
+			if !strings.EqualFold(email, data.Forwarder) {
+				contact.Emails = append(contact.Emails, helper.TypedValue{Value: email, Type: helper.TypeWork})
+			}
+		}
+		if contact.Title == "" && signature_detector.TitleRegexp.MatchString(line) && len(line) < 80 {
+			contact.Title, contact.Organization = splitTitle(line, contact.Organization)
+		}
+		if contact.Organization == "" && heuristicCompanyRegexp.MatchString(line) {
+			contact.Organization = line
+		}
+		for _, url := range heuristicUrlRegexp.FindAllString(line, -1) {
+			url = strings.TrimRight(url, ".,;")
+			switch {
+			case strings.Contains(strings.ToLower(url), "linkedin.com/"):
+				if contact.LinkedIn == "" {
+					contact.LinkedIn = url
+				}
+			case contact.Website == "":
+				contact.Website = url
+			}
+		}
+	}
+	if contact.Organization == "" {
+		contact.Organization = domainOrganization(contact.Email)
+	}
+	if contact.IsEmpty() {
+		return nil, fmt.Errorf("no contact data found")
+	}
+	return contact, nil
+}
+
+func (h *HeuristicExtractor) Close() error {
+	return nil
+}
+
+func NewFallbackExtractor(primary, fallback Extractor) *FallbackExtractor {
+	return &FallbackExtractor{primary: primary, fallback: fallback}
+}
+
+func (f *FallbackExtractor) Generate(ctx context.Context, data MailData) (*helper.Contact, error) {
+	contact, err := f.primary.Generate(ctx, data)
+	if err == nil {
+		return contact, nil
+	}
+	log.Printf("Extractor failed, using fallback: %v", err)
+	return f.fallback.Generate(ctx, data)
+}
+
+func (f *FallbackExtractor) Close() error {
+	err := f.primary.Close()
+	if fallbackErr := f.fallback.Close(); err == nil {
+		err = fallbackErr
+	}
+	return err
+}
+
+func signatureName(signature string) string {
+	lines := strings.Split(signature, "\n")
+	for i, line := range lines {
+		line = strings.Trim(line, " \t|,")
+		if line == "" || signature_detector.SignOffRegexp.MatchString(line) {
+			continue
+		}
+		if isPersonName(line) {
+			return line
+		}
+		if i > 2 {
+			break
+		}
+	}
+	return ""
+}
+
+func isPersonName(text string) bool {
+	words := strings.Fields(text)
+	if len(words) < 2 || len(words) > 4 {
+		return false
+	}
+	for _, word := range words {
+		r := []rune(strings.Trim(word, ","))
+		if len(r) < 2 || !unicode.IsUpper(r[0]) {
+			return false
+		}
+		for _, c := range r[1:] {
+			if !unicode.IsLetter(c) && c != '-' && c != '\'' && c != '.' {
+				return false
+			}
+		}
+	}
+	return !signature_detector.TitleRegexp.MatchString(text) && !heuristicCompanyRegexp.MatchString(text)
+}
+
+func splitName(name string) (string, string) {
+	name = strings.Trim(strings.TrimSpace(name), `"'`)
+	if first, last, ok := strings.Cut(name, ","); ok {
+		return strings.TrimSpace(last), strings.TrimSpace(first)
+	}
+	words := strings.Fields(name)
+	switch len(words) {
+	case 0:
+		return "", ""
+	case 1:
+		return words[0], ""
+	}
+	return strings.Join(words[:len(words)-1], " "), words[len(words)-1]
+}
+
+func splitTitle(line, organization string) (string, string) {
+	for _, separator := range []string{" | ", " at ", " @ ", ", ", " - ", " – "} {
+		if title, company, ok := strings.Cut(line, separator); ok && signature_detector.TitleRegexp.MatchString(title) {
+			if organization == "" {
+				organization = strings.TrimSpace(company)
+			}
+			return strings.TrimSpace(title), organization
+		}
+	}
+	return line, organization
+}
+
+func firstEmail(sources []string, forwarder string) string {
+	for _, source := range sources {
+		for _, email := range signature_detector.EmailRegexp.FindAllString(source, -1) {
+			if !strings.EqualFold(email, forwarder) {
+				return email
+			}
+		}
+	}
+	return ""
+}
+
+func firstPhone(sources []string) string {
+	for _, source := range sources {
+		if found := phones(source); len(found) > 0 {
+			return found[0]
+		}
+	}
+	return ""
+}
+
+func phones(text string) []string {
+	var found []string
+	for _, match := range signature_detector.PhoneRegexp.FindAllString(text, -1) {
+		digits := strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, match)
+		if len(digits) >= minPhoneDigits && len(digits) <= 15 && !dateRegexp.MatchString(match) {
+			found = append(found, strings.TrimSpace(match))
+		}
+	}
+	return found
+}
+
+func phoneType(line string) string {
+	line = strings.ToLower(line)
+	switch {
+	case strings.Contains(line, "fax"):
+		return helper.TypeFax
+	case strings.Contains(line, "mob") || strings.Contains(line, "cell") || strings.Contains(line, "kom"):
+		return helper.TypeMobile
+	case strings.Contains(line, "tel") || strings.Contains(line, "phone") || strings.Contains(line, "office") || strings.Contains(line, "biuro"):
+		return helper.TypeWork
+	}
+	return helper.TypeOther
+}
+
+func domainOrganization(email string) string {
+	_, domain, ok := strings.Cut(strings.ToLower(email), "@")
+	if !ok {
+		return ""
+	}
+	for _, free := range freeMailDomains {
+		if domain == free {
+			return ""
+		}
+	}
+	labels := strings.FieldsFunc(domain, func(r rune) bool {
+		return r == '.'
+	})
+	if len(labels) < 2 {
+		return ""
+	}
+	name := labels[len(labels)-2]
+	if len(labels) > 2 && (name == "co" || name == "com") {
+		name = labels[len(labels)-3]
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
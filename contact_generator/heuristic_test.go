@@ -0,0 +1,61 @@
+package contact_generator
+
+import (
+	"MailContactUtilty/mail_parser"
+	"context"
+	"testing"
+)
+
+func TestDomainOrganization(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{email: "jan@acme.com", want: "Acme"},
+		{email: "jan@mail.acme.co.uk", want: "Acme"},
+		{email: "jan@gmail.com", want: ""},
+		{email: "jan@a..com", want: "A"},
+		{email: "jan@..com", want: ""},
+		{email: "jan@.", want: ""},
+		{email: "jan", want: ""},
+	}
+	for _, test := range tests {
+		if got := domainOrganization(test.email); got != test.want {
+			t.Errorf("domainOrganization(%q) = %q, want %q", test.email, got, test.want)
+		}
+	}
+}
+
+func TestHeuristicForwardedInvalidAddress(t *testing.T) {
+	original := mail_parser.ParseForwarded("---------- Forwarded message ---------\nFrom: Jan <jan@a..com>\nSubject: Hello\n\nHi")
+	if original == nil {
+		t.Fatal("forwarded message not detected")
+	}
+	contact, err := NewHeuristicExtractor().Generate(context.Background(), MailData{Text: original.Body, Original: original})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if contact.Name != "Jan" || contact.Surname != "" || contact.Email != "" {
+		t.Errorf("unexpected contact: %+v", contact)
+	}
+}
+
+func TestHeuristicSignature(t *testing.T) {
+	text := "Hi, please add me.\n\nBest regards,\nAnna Nowak\nVP Marketing | Acme sp. z o.o.\nMob. +48 600 100 200\nanna.nowak@acme.pl\nwww.acme.pl"
+	contact, err := NewHeuristicExtractor().Generate(context.Background(), MailData{Text: text, Forwarder: "me@example.com"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if contact.Name != "Anna" || contact.Surname != "Nowak" {
+		t.Errorf("name = %q %q", contact.Name, contact.Surname)
+	}
+	if contact.Email != "anna.nowak@acme.pl" || contact.Phone != "+48 600 100 200" {
+		t.Errorf("email = %q, phone = %q", contact.Email, contact.Phone)
+	}
+	if contact.Title != "VP Marketing" || contact.Organization != "Acme sp. z o.o." {
+		t.Errorf("title = %q, organization = %q", contact.Title, contact.Organization)
+	}
+	if len(contact.Phones) != 1 || contact.Phones[0].Type != "mobile" {
+		t.Errorf("phones = %+v", contact.Phones)
+	}
+}
@@ -0,0 +1,176 @@
+package contact_generator
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"testing"
+
+	"golang.org/x/image/vector"
+)
+
+// Simple Icons path data on a 24x24 canvas.
+var socialIconPaths = map[string]string{
+	"linkedin": "M20.447 20.452h-3.554v-5.569c0-1.328-.027-3.037-1.852-3.037-1.853 0-2.136 1.445-2.136 2.939v5.667H9.351V9h3.414v1.561h.046c.477-.9 1.637-1.85 3.37-1.85 3.601 0 4.267 2.37 4.267 5.455v6.286zM5.337 7.433c-1.144 0-2.063-.926-2.063-2.065 0-1.138.92-2.063 2.063-2.063 1.14 0 2.065.925 2.065 2.063 0 1.139-.925 2.065-2.065 2.065zm1.782 13.019H3.555V9h3.564v11.452zM22.225 0H1.771C.792 0 0 .774 0 1.729v20.542C0 23.227.792 24 1.771 24h20.451C23.2 24 24 23.227 24 22.271V1.729C24 .774 23.2 0 22.222 0h.003z",
+	"x":        "M18.901 1.153h3.68l-8.04 9.19L24 22.846h-7.406l-5.8-7.584-6.638 7.584H.474l8.6-9.83L0 1.154h7.594l5.243 6.932ZM17.61 20.644h2.039L6.486 3.24H4.298Z",
+	"facebook": "M9.101 23.691v-7.98H6.627v-3.667h2.474v-1.58c0-4.085 1.848-5.978 5.858-5.978.401 0 .955.042 1.468.103a8.68 8.68 0 0 1 1.141.195v3.325a8.623 8.623 0 0 0-.653-.036 26.805 26.805 0 0 0-.733-.009c-.707 0-1.259.096-1.675.309a1.686 1.686 0 0 0-.679.622c-.258.42-.374.995-.374 1.752v1.297h3.919l-.386 2.103-.287 1.564h-3.246v8.245C19.396 23.238 24 18.179 24 12.044c0-6.627-5.373-12-12-12s-12 5.373-12 12c0 5.216 3.328 9.654 7.977 11.302.382.108.761.201 1.124.345Z",
+	"youtube":  "M23.498 6.186a3.016 3.016 0 0 0-2.122-2.136C19.505 3.545 12 3.545 12 3.545s-7.505 0-9.377.505A3.017 3.017 0 0 0 .502 6.186C0 8.07 0 12 0 12s0 3.93.502 5.814a3.016 3.016 0 0 0 2.122 2.136c1.871.505 9.376.505 9.376.505s7.505 0 9.377-.505a3.015 3.015 0 0 0 2.122-2.136C24 15.93 24 12 24 12s0-3.93-.502-5.814zM9.545 15.568V8.432L15.818 12l-6.273 3.568z",
+}
+
+// renderIcon fills an icon path in fg over bg, drawing arcs as straight
+// segments, which is close enough for a 9x8 difference hash.
+func renderIcon(t *testing.T, path string, size int, fg, bg color.Color) image.Image {
+	t.Helper()
+	scale := float32(size) / 24
+	z := vector.NewRasterizer(size, size)
+	var x, y, startX, startY, ctrlX, ctrlY float32
+	var cmd byte
+	i := 0
+	number := func() float32 {
+		for i < len(path) && (path[i] == ' ' || path[i] == ',') {
+			i++
+		}
+		start := i
+		if i < len(path) && (path[i] == '-' || path[i] == '+') {
+			i++
+		}
+		dot := false
+		for i < len(path) && (path[i] >= '0' && path[i] <= '9' || path[i] == '.' && !dot) {
+			dot = dot || path[i] == '.'
+			i++
+		}
+		v, err := strconv.ParseFloat(path[start:i], 32)
+		if err != nil {
+			t.Fatalf("invalid path number at %d: %v", start, err)
+		}
+		return float32(v)
+	}
+	for i < len(path) {
+		switch c := path[i]; {
+		case c == ' ' || c == ',':
+			i++
+			continue
+		case c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			cmd = c
+			i++
+			if c == 'Z' || c == 'z' {
+				z.ClosePath()
+				x, y = startX, startY
+				continue
+			}
+		}
+		relX, relY := float32(0), float32(0)
+		if cmd >= 'a' {
+			relX, relY = x, y
+		}
+		switch cmd | 0x20 {
+		case 'm':
+			x, y = relX+number(), relY+number()
+			startX, startY = x, y
+			z.MoveTo(x*scale, y*scale)
+			if cmd == 'm' {
+				cmd = 'l'
+			} else {
+				cmd = 'L'
+			}
+		case 'l':
+			x, y = relX+number(), relY+number()
+			z.LineTo(x*scale, y*scale)
+		case 'h':
+			x = relX + number()
+			z.LineTo(x*scale, y*scale)
+		case 'v':
+			y = relY + number()
+			z.LineTo(x*scale, y*scale)
+		case 'c':
+			x1, y1 := relX+number(), relY+number()
+			ctrlX, ctrlY = relX+number(), relY+number()
+			x, y = relX+number(), relY+number()
+			z.CubeTo(x1*scale, y1*scale, ctrlX*scale, ctrlY*scale, x*scale, y*scale)
+			continue
+		case 's':
+			x1, y1 := 2*x-ctrlX, 2*y-ctrlY
+			ctrlX, ctrlY = relX+number(), relY+number()
+			x, y = relX+number(), relY+number()
+			z.CubeTo(x1*scale, y1*scale, ctrlX*scale, ctrlY*scale, x*scale, y*scale)
+			continue
+		case 'a':
+			for range 5 {
+				number()
+			}
+			x, y = relX+number(), relY+number()
+			z.LineTo(x*scale, y*scale)
+		default:
+			t.Fatalf("unsupported path command %q", cmd)
+		}
+		ctrlX, ctrlY = x, y
+	}
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	z.Draw(img, img.Bounds(), image.NewUniform(fg), image.Point{})
+	return img
+}
+
+func iconPng(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFilterSkipsBuiltinIcons(t *testing.T) {
+	filter, err := NewImageFilter(ImageFilterConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	colors := []struct {
+		name   string
+		fg, bg color.Color
+	}{
+		{name: "brand", fg: color.RGBA{10, 102, 194, 255}, bg: color.White},
+		{name: "black", fg: color.Black, bg: color.White},
+		{name: "inverted", fg: color.White, bg: color.RGBA{24, 119, 242, 255}},
+	}
+	for name, path := range socialIconPaths {
+		if name == "youtube" {
+			continue
+		}
+		for _, c := range colors {
+			for _, size := range []int{32, 48, 64, 128} {
+				img := ImageData{Type: "image/png", Data: iconPng(t, renderIcon(t, path, size, c.fg, c.bg))}
+				if kept := filter.Filter([]ImageData{img}); len(kept) != 0 {
+					t.Errorf("%s icon (%s, %dpx) was not skipped", name, c.name, size)
+				}
+			}
+		}
+	}
+	photo := ImageData{Type: "image/png", Data: encodePng(t, 64, 64)}
+	if kept := filter.Filter([]ImageData{photo}); len(kept) != 1 {
+		t.Error("a regular image was skipped as a known icon")
+	}
+}
+
+func TestFilterExactHashDistance(t *testing.T) {
+	icon := renderIcon(t, socialIconPaths["youtube"], 64, color.Black, color.White)
+	hash := DHash(icon)
+	distance := 0
+	filter, err := NewImageFilter(ImageFilterConfig{HashDistance: &distance, KnownHashes: []string{strconv.FormatUint(hash, 16)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept := filter.Filter([]ImageData{{Type: "image/png", Data: iconPng(t, icon)}}); len(kept) != 0 {
+		t.Error("exact match was not skipped")
+	}
+	if kept := filter.Filter([]ImageData{{Type: "image/png", Data: iconPng(t, renderIcon(t, socialIconPaths["youtube"], 64, color.White, color.Black))}}); len(kept) != 1 {
+		t.Error("a different image was skipped with an exact hash distance")
+	}
+	distance = -1
+	if _, err := NewImageFilter(ImageFilterConfig{HashDistance: &distance}); err == nil {
+		t.Error("NewImageFilter accepted a negative hash distance")
+	}
+}
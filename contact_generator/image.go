@@ -0,0 +1,118 @@
+package contact_generator
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"strings"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	maxImageDimension = 1568
+	minImageDimension = 256
+	maxImageBytes     = 4 << 20
+	maxImagePixels    = 40_000_000
+	jpegQuality       = 85
+)
+
+func PrepareImages(images []ImageData) []ImageData {
+	prepared := make([]ImageData, 0, len(images))
+	for _, img := range images {
+		result, err := PrepareImage(img)
+		if err != nil {
+			log.Printf("Skipping image: %v", err)
+			continue
+		}
+		result.Position = img.Position
+		prepared = append(prepared, result)
+	}
+	return prepared
+}
+
+func PrepareImage(img ImageData) (ImageData, error) {
+	if strings.HasPrefix(strings.ToLower(img.Type), "image/svg") {
+		return ImageData{}, fmt.Errorf("%s images are not supported", img.Type)
+	}
+	decoded, format, err := decodeImage(img.Data)
+	if err != nil {
+		return ImageData{}, fmt.Errorf("unable to decode %s image: %w", img.Type, err)
+	}
+	bounds := decoded.Bounds()
+	if bounds.Dx() <= maxImageDimension && bounds.Dy() <= maxImageDimension && len(img.Data) <= maxImageBytes && (format == "jpeg" || format == "png") {
+		return ImageData{Type: "image/" + format, Data: img.Data, decoded: decoded}, nil
+	}
+	for dimension := maxImageDimension; ; dimension /= 2 {
+		result, err := encodeImage(downscale(decoded, dimension))
+		if err != nil {
+			return ImageData{}, err
+		}
+		if len(result.Data) <= maxImageBytes || dimension <= minImageDimension {
+			result.decoded = decoded
+			return result, nil
+		}
+	}
+}
+
+func decodeImage(data []byte) (image.Image, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return nil, "", fmt.Errorf("%s image dimensions %dx%d exceed the limit of %d pixels", format, config.Width, config.Height, maxImagePixels)
+	}
+	return image.Decode(bytes.NewReader(data))
+}
+
+func downscale(src image.Image, dimension int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= dimension && height <= dimension {
+		return src
+	}
+	if width >= height {
+		height = max(height*dimension/width, 1)
+		width = dimension
+	} else {
+		width = max(width*dimension/height, 1)
+		height = dimension
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
+
+func encodeImage(img image.Image) (ImageData, error) {
+	var buf bytes.Buffer
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && !opaque.Opaque() {
+		if err := png.Encode(&buf, img); err != nil {
+			return ImageData{}, fmt.Errorf("unable to encode image: %w", err)
+		}
+		if buf.Len() <= maxImageBytes {
+			return ImageData{Type: "image/png", Data: buf.Bytes()}, nil
+		}
+		buf.Reset()
+		img = flatten(img)
+	}
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return ImageData{}, fmt.Errorf("unable to encode image: %w", err)
+	}
+	return ImageData{Type: "image/jpeg", Data: buf.Bytes()}, nil
+}
+
+func flatten(src image.Image) image.Image {
+	dst := image.NewRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Over)
+	return dst
+}
@@ -0,0 +1,99 @@
+package contact_generator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+func encodePng(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 255 / width), uint8(y * 255 / height), 0, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func oversizedPng(t *testing.T) []byte {
+	data := encodePng(t, 1, 1)
+	ihdr := data[8+8 : 8+8+13]
+	binary.BigEndian.PutUint32(ihdr[0:4], 50000)
+	binary.BigEndian.PutUint32(ihdr[4:8], 50000)
+	binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(data[8+4:8+8+13]))
+	return data
+}
+
+func oversizedGif(t *testing.T) []byte {
+	var buf bytes.Buffer
+	img := image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White})
+	if err := gif.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	binary.LittleEndian.PutUint16(data[6:8], 50000)
+	binary.LittleEndian.PutUint16(data[8:10], 50000)
+	return data
+}
+
+func TestPrepareImageRejectsOversizedDimensions(t *testing.T) {
+	for _, img := range []ImageData{
+		{Type: "image/png", Data: oversizedPng(t)},
+		{Type: "image/gif", Data: oversizedGif(t)},
+	} {
+		if _, err := PrepareImage(img); err == nil {
+			t.Errorf("PrepareImage(%s) accepted a 50000x50000 image", img.Type)
+		}
+	}
+}
+
+func TestPrepareImageDownscales(t *testing.T) {
+	prepared, err := PrepareImage(ImageData{Type: "image/png", Data: encodePng(t, 2000, 1000)})
+	if err != nil {
+		t.Fatalf("PrepareImage: %v", err)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(prepared.Data))
+	if err != nil {
+		t.Fatalf("decoding prepared image: %v", err)
+	}
+	if config.Width != maxImageDimension || config.Height != maxImageDimension/2 {
+		t.Errorf("prepared image is %dx%d", config.Width, config.Height)
+	}
+	if prepared.decoded == nil {
+		t.Error("prepared image does not carry the decoded image")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	filter, err := NewImageFilter(ImageFilterConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logo := ImageData{Type: "image/png", Data: encodePng(t, 64, 64)}
+	images := PrepareImages([]ImageData{
+		logo,
+		{Type: "image/png", Data: encodePng(t, 16, 16)},
+		logo,
+		{Type: "image/png", Data: oversizedPng(t)},
+	})
+	if len(images) != 3 {
+		t.Fatalf("PrepareImages kept %d images, want 3", len(images))
+	}
+	if filtered := filter.Filter(images); len(filtered) != 1 {
+		t.Errorf("Filter kept %d images, want 1", len(filtered))
+	}
+	if filtered := filter.Filter([]ImageData{{Type: "image/png", Data: oversizedPng(t)}}); len(filtered) != 0 {
+		t.Errorf("Filter kept an oversized image")
+	}
+}
@@ -0,0 +1,103 @@
+package contact_generator
+
+import (
+	"MailContactUtilty/helper"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type OllamaGenerator struct {
+	config ExtractorConfig
+	client *http.Client
+	filter *ImageFilter
+}
+
+const (
+	defaultOllamaBaseUrl = "http://localhost:11434"
+	defaultOllamaModel   = "llama3.2-vision"
+)
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Format   map[string]any  `json:"format"`
+	Stream   bool            `json:"stream"`
+}
+
+type ollamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+type ollamaResponse struct {
+	Message ollamaMessage `json:"message"`
+}
+
+func NewOllamaGenerator(config ExtractorConfig) (*OllamaGenerator, error) {
+	filter, err := NewImageFilter(config.ImageFilter)
+	if err != nil {
+		return nil, err
+	}
+	if config.BaseUrl == "" {
+		config.BaseUrl = defaultOllamaBaseUrl
+	}
+	if config.Model == "" {
+		config.Model = defaultOllamaModel
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &OllamaGenerator{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		filter: filter,
+	}, nil
+}
+
+func (o *OllamaGenerator) Generate(ctx context.Context, data MailData) (*helper.Contact, error) {
+	var texts, images []string
+	for _, part := range buildPrompt(o.filter, data) {
+		switch {
+		case part.Image != nil:
+			images = append(images, base64.StdEncoding.EncodeToString(part.Image.Data))
+		case part.Document != nil && part.Document.Text != "":
+			texts = append(texts, documentTextPrompt+part.Document.Text)
+		case part.Document != nil:
+			log.Printf("Skipping %s document without a text layer", part.Document.Type)
+		case part.Text == signatureImageLabel || part.Text == attachedImageLabel:
+		default:
+			texts = append(texts, part.Text)
+		}
+	}
+	request := ollamaRequest{
+		Model: o.config.Model,
+		Messages: []ollamaMessage{{
+			Role:    "user",
+			Content: strings.Join(texts, "\n\n"),
+			Images:  images,
+		}},
+		Format: contactSchema(),
+	}
+	headers := map[string]string{}
+	if o.config.ApiKey != "" {
+		headers["Authorization"] = "Bearer " + o.config.ApiKey
+	}
+	var response ollamaResponse
+	if err := postJSON(ctx, o.client, strings.TrimSuffix(o.config.BaseUrl, "/")+"/api/chat", headers, request, &response); err != nil {
+		return nil, err
+	}
+	if response.Message.Content == "" {
+		return nil, fmt.Errorf("no valid response found")
+	}
+	return parseContact(response.Message.Content)
+}
+
+func (o *OllamaGenerator) Close() error {
+	o.client.CloseIdleConnections()
+	return nil
+}
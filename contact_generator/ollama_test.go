@@ -0,0 +1,77 @@
+package contact_generator
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOllamaGenerator(t *testing.T) {
+	server, requests := newStubServer(t, http.StatusOK, map[string]any{"message": map[string]any{"role": "assistant", "content": stubContact}})
+	generator, err := NewOllamaGenerator(ExtractorConfig{BaseUrl: server.URL, Model: "test-model"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer generator.Close()
+	data := stubMailData(t)
+	contact, err := generator.Generate(context.Background(), data)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	checkStubContact(t, contact)
+
+	request := <-requests
+	if request.path != "/api/chat" {
+		t.Errorf("path = %q", request.path)
+	}
+	if request.authorization != "" {
+		t.Errorf("Authorization = %q, want none without an api key", request.authorization)
+	}
+	var body struct {
+		Model    string          `json:"model"`
+		Messages []ollamaMessage `json:"messages"`
+		Format   map[string]any  `json:"format"`
+		Stream   *bool           `json:"stream"`
+	}
+	if err := json.Unmarshal(request.body, &body); err != nil {
+		t.Fatalf("decoding request: %v", err)
+	}
+	if body.Model != "test-model" {
+		t.Errorf("model = %q", body.Model)
+	}
+	if body.Stream == nil || *body.Stream {
+		t.Errorf("stream = %v, want false", body.Stream)
+	}
+	checkContactSchema(t, body.Format)
+	if len(body.Messages) != 1 || body.Messages[0].Role != "user" {
+		t.Fatalf("messages = %+v", body.Messages)
+	}
+	message := body.Messages[0]
+	if !strings.Contains(message.Content, data.Text) {
+		t.Errorf("content does not include the mail text: %q", message.Content)
+	}
+	if strings.Contains(message.Content, attachedImageLabel) {
+		t.Errorf("content includes the image label: %q", message.Content)
+	}
+	if len(message.Images) != 1 {
+		t.Fatalf("request has %d images, want 1", len(message.Images))
+	}
+	if _, err := base64.StdEncoding.DecodeString(message.Images[0]); err != nil {
+		t.Errorf("image is not base64: %v", err)
+	}
+}
+
+func TestOllamaGeneratorEmptyResponse(t *testing.T) {
+	server, _ := newStubServer(t, http.StatusOK, map[string]any{"message": map[string]any{"role": "assistant", "content": ""}})
+	generator, err := NewOllamaGenerator(ExtractorConfig{BaseUrl: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer generator.Close()
+	if _, err := generator.Generate(context.Background(), MailData{Text: "Hi"}); err == nil || !strings.Contains(err.Error(), "no valid response") {
+		t.Errorf("Generate error = %v", err)
+	}
+}
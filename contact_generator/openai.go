@@ -0,0 +1,137 @@
+package contact_generator
+
+import (
+	"MailContactUtilty/helper"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type OpenAIGenerator struct {
+	config ExtractorConfig
+	client *http.Client
+	filter *ImageFilter
+}
+
+const (
+	defaultOpenAIBaseUrl = "https://api.openai.com/v1"
+	defaultOpenAIModel   = "gpt-4o-mini"
+)
+
+type openAIRequest struct {
+	Model          string               `json:"model"`
+	Messages       []openAIMessage      `json:"messages"`
+	ResponseFormat openAIResponseFormat `json:"response_format"`
+}
+
+type openAIMessage struct {
+	Role    string          `json:"role"`
+	Content []openAIContent `json:"content"`
+}
+
+type openAIContent struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageUrl *openAIImageUrl `json:"image_url,omitempty"`
+}
+
+type openAIImageUrl struct {
+	Url string `json:"url"`
+}
+
+type openAIResponseFormat struct {
+	Type       string           `json:"type"`
+	JsonSchema openAIJsonSchema `json:"json_schema"`
+}
+
+type openAIJsonSchema struct {
+	Name   string         `json:"name"`
+	Strict bool           `json:"strict"`
+	Schema map[string]any `json:"schema"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+			Refusal string `json:"refusal"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+func NewOpenAIGenerator(config ExtractorConfig) (*OpenAIGenerator, error) {
+	filter, err := NewImageFilter(config.ImageFilter)
+	if err != nil {
+		return nil, err
+	}
+	if config.BaseUrl == "" {
+		config.BaseUrl = defaultOpenAIBaseUrl
+	}
+	if config.Model == "" {
+		config.Model = defaultOpenAIModel
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &OpenAIGenerator{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		filter: filter,
+	}, nil
+}
+
+func (o *OpenAIGenerator) Generate(ctx context.Context, data MailData) (*helper.Contact, error) {
+	var content []openAIContent
+	for _, part := range buildPrompt(o.filter, data) {
+		switch {
+		case part.Image != nil:
+			content = append(content, openAIContent{
+				Type:     "image_url",
+				ImageUrl: &openAIImageUrl{Url: "data:" + part.Image.Type + ";base64," + base64.StdEncoding.EncodeToString(part.Image.Data)},
+			})
+		case part.Document != nil && part.Document.Text != "":
+			content = append(content, openAIContent{Type: "text", Text: documentTextPrompt + part.Document.Text})
+		case part.Document != nil:
+			log.Printf("Skipping %s document without a text layer", part.Document.Type)
+		default:
+			content = append(content, openAIContent{Type: "text", Text: part.Text})
+		}
+	}
+	request := openAIRequest{
+		Model:    o.config.Model,
+		Messages: []openAIMessage{{Role: "user", Content: content}},
+		ResponseFormat: openAIResponseFormat{
+			Type: "json_schema",
+			JsonSchema: openAIJsonSchema{
+				Name:   "contact",
+				Strict: true,
+				Schema: contactSchema(),
+			},
+		},
+	}
+	headers := map[string]string{}
+	if o.config.ApiKey != "" {
+		headers["Authorization"] = "Bearer " + o.config.ApiKey
+	}
+	var response openAIResponse
+	if err := postJSON(ctx, o.client, strings.TrimSuffix(o.config.BaseUrl, "/")+"/chat/completions", headers, request, &response); err != nil {
+		return nil, err
+	}
+	for _, choice := range response.Choices {
+		if choice.Message.Refusal != "" {
+			return nil, fmt.Errorf("model refused: %s", choice.Message.Refusal)
+		}
+		if choice.Message.Content != "" {
+			return parseContact(choice.Message.Content)
+		}
+	}
+	return nil, fmt.Errorf("no valid response found")
+}
+
+func (o *OpenAIGenerator) Close() error {
+	o.client.CloseIdleConnections()
+	return nil
+}
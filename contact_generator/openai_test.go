@@ -0,0 +1,177 @@
+package contact_generator
+
+import (
+	"MailContactUtilty/helper"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const stubContact = `{"name":"Jan","surname":"Kowalski","email":"jan@acme.pl","phone":"+48 600 100 200","organization":"Acme","title":"CEO","department":"","address":"","website":"","linkedin":"","emails":[{"value":"jan@acme.pl","type":"work"}],"phones":[{"value":"+48 600 100 200","type":"mobile"}]}`
+
+type stubRequest struct {
+	path          string
+	authorization string
+	body          []byte
+}
+
+func newStubServer(t *testing.T, status int, response any) (*httptest.Server, chan stubRequest) {
+	t.Helper()
+	requests := make(chan stubRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- stubRequest{path: r.URL.Path, authorization: r.Header.Get("Authorization"), body: body}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func stubMailData(t *testing.T) MailData {
+	return MailData{
+		Text:   "Hi,\n\nJan Kowalski\nCEO, Acme",
+		Images: []ImageData{{Type: "image/png", Data: encodePng(t, 64, 64), Position: ImagePositionAttached}},
+	}
+}
+
+func checkContactSchema(t *testing.T, schema map[string]any) {
+	t.Helper()
+	if schema["type"] != "object" || schema["additionalProperties"] != false {
+		t.Errorf("schema is not a closed object: %v", schema)
+	}
+	required, _ := schema["required"].([]any)
+	for _, field := range append(slices.Clone(contactFields), "emails", "phones") {
+		if !slices.Contains(required, any(field)) {
+			t.Errorf("schema does not require %s", field)
+		}
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	phones, _ := properties["phones"].(map[string]any)
+	items, _ := phones["items"].(map[string]any)
+	itemProperties, _ := items["properties"].(map[string]any)
+	phoneType, _ := itemProperties["type"].(map[string]any)
+	if enum, _ := phoneType["enum"].([]any); !slices.Contains(enum, any(helper.TypeMobile)) {
+		t.Errorf("phone type enum = %v", phoneType["enum"])
+	}
+}
+
+func checkStubContact(t *testing.T, contact *helper.Contact) {
+	t.Helper()
+	if contact.Name != "Jan" || contact.Surname != "Kowalski" || contact.Organization != "Acme" {
+		t.Errorf("contact = %+v", contact)
+	}
+	if len(contact.Phones) != 1 || contact.Phones[0].Type != helper.TypeMobile {
+		t.Errorf("phones = %+v", contact.Phones)
+	}
+}
+
+func openAIChoice(content, refusal string) map[string]any {
+	return map[string]any{"choices": []any{map[string]any{"message": map[string]any{"content": content, "refusal": refusal}}}}
+}
+
+func TestOpenAIGenerator(t *testing.T) {
+	server, requests := newStubServer(t, http.StatusOK, openAIChoice(stubContact, ""))
+	generator, err := NewOpenAIGenerator(ExtractorConfig{BaseUrl: server.URL + "/v1/", Model: "test-model", ApiKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer generator.Close()
+	contact, err := generator.Generate(context.Background(), stubMailData(t))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	checkStubContact(t, contact)
+
+	request := <-requests
+	if request.path != "/v1/chat/completions" {
+		t.Errorf("path = %q", request.path)
+	}
+	if request.authorization != "Bearer secret" {
+		t.Errorf("Authorization = %q", request.authorization)
+	}
+	var body struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content []openAIContent `json:"content"`
+		} `json:"messages"`
+		ResponseFormat struct {
+			Type       string `json:"type"`
+			JsonSchema struct {
+				Name   string         `json:"name"`
+				Strict bool           `json:"strict"`
+				Schema map[string]any `json:"schema"`
+			} `json:"json_schema"`
+		} `json:"response_format"`
+	}
+	if err := json.Unmarshal(request.body, &body); err != nil {
+		t.Fatalf("decoding request: %v", err)
+	}
+	if body.Model != "test-model" {
+		t.Errorf("model = %q", body.Model)
+	}
+	if body.ResponseFormat.Type != "json_schema" || body.ResponseFormat.JsonSchema.Name != "contact" || !body.ResponseFormat.JsonSchema.Strict {
+		t.Errorf("response_format = %+v", body.ResponseFormat)
+	}
+	checkContactSchema(t, body.ResponseFormat.JsonSchema.Schema)
+	if len(body.Messages) != 1 || body.Messages[0].Role != "user" {
+		t.Fatalf("messages = %+v", body.Messages)
+	}
+	var images, texts int
+	for _, content := range body.Messages[0].Content {
+		switch content.Type {
+		case "image_url":
+			images++
+			if content.ImageUrl == nil || !strings.HasPrefix(content.ImageUrl.Url, "data:image/png;base64,") {
+				t.Errorf("image_url = %+v", content.ImageUrl)
+			}
+		case "text":
+			texts++
+		default:
+			t.Errorf("unexpected content type %q", content.Type)
+		}
+	}
+	if images != 1 || texts == 0 {
+		t.Errorf("request has %d images and %d texts", images, texts)
+	}
+}
+
+func TestOpenAIGeneratorErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response any
+		wantErr  string
+	}{
+		{name: "refusal", status: http.StatusOK, response: openAIChoice("", "cannot help"), wantErr: "model refused"},
+		{name: "no choices", status: http.StatusOK, response: map[string]any{"choices": []any{}}, wantErr: "no valid response"},
+		{name: "invalid contact", status: http.StatusOK, response: openAIChoice("not json", ""), wantErr: "invalid contact response"},
+		{name: "server error", status: http.StatusServiceUnavailable, response: map[string]any{"error": "overloaded"}, wantErr: "503"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, _ := newStubServer(t, test.status, test.response)
+			generator, err := NewOpenAIGenerator(ExtractorConfig{BaseUrl: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer generator.Close()
+			_, err = generator.Generate(context.Background(), MailData{Text: "Hi"})
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("Generate error = %v, want %q", err, test.wantErr)
+			}
+			var statusErr *StatusError
+			if errors.As(err, &statusErr) != (test.status != http.StatusOK) {
+				t.Errorf("Generate error %v has unexpected type %T", err, err)
+			}
+		})
+	}
+}
@@ -2,72 +2,123 @@ package contact_generator
 
 import (
 	"MailContactUtilty/helper"
+	"MailContactUtilty/mail_parser"
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"image"
+	"strings"
+	"time"
 
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
 )
 
 type ContactGenerator struct {
-	client *genai.Client
-	model  *genai.GenerativeModel
+	client  *genai.Client
+	model   *genai.GenerativeModel
+	filter  *ImageFilter
+	timeout time.Duration
 }
+
+const defaultGeminiModel = "gemini-2.0-flash-lite"
+
 type ImageData struct {
+	Type     string
+	Data     []byte
+	Position string
+	decoded  image.Image
+}
+
+const (
+	ImagePositionSignature = "signature"
+	ImagePositionBody      = "body"
+	ImagePositionAttached  = "attached"
+)
+
+type DocumentData struct {
 	Type string
 	Data []byte
+	Text string
 }
 
-func NewContactGenerator(ctx context.Context, apiKey string) (*ContactGenerator, error) {
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+type MailData struct {
+	Text      string
+	Images    []ImageData
+	Documents []DocumentData
+	Signature string
+	Forwarder string
+	Original  *mail_parser.ForwardedMessage
+}
+
+func NewContactGenerator(ctx context.Context, config ExtractorConfig) (*ContactGenerator, error) {
+	filter, err := NewImageFilter(config.ImageFilter)
 	if err != nil {
 		return nil, err
 	}
-	model := client.GenerativeModel("gemini-2.0-flash-lite")
+	if config.Model == "" {
+		config.Model = defaultGeminiModel
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	opts := []option.ClientOption{option.WithAPIKey(config.ApiKey)}
+	if config.BaseUrl != "" {
+		opts = append(opts, option.WithEndpoint(config.BaseUrl))
+	}
+	client, err := genai.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	properties := map[string]*genai.Schema{}
+	for _, field := range contactFields {
+		properties[field] = &genai.Schema{Type: genai.TypeString}
+	}
+	for _, field := range typedFields {
+		properties[field.name] = &genai.Schema{
+			Type: genai.TypeArray,
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"value": {Type: genai.TypeString},
+					"type":  {Type: genai.TypeString, Format: "enum", Enum: field.types},
+				},
+				Required: []string{"value", "type"},
+			},
+		}
+	}
+	model := client.GenerativeModel(config.Model)
 	model.ResponseMIMEType = "application/json"
 	model.ResponseSchema = &genai.Schema{
-		Type: genai.TypeObject,
-		Properties: map[string]*genai.Schema{
-			"Name":         {Type: genai.TypeString},
-			"Surname":      {Type: genai.TypeString},
-			"Email":        {Type: genai.TypeString},
-			"Phone":        {Type: genai.TypeString},
-			"Organization": {Type: genai.TypeString},
-		},
+		Type:       genai.TypeObject,
+		Properties: properties,
 	}
 	return &ContactGenerator{
-		model:  model,
-		client: client,
+		model:   model,
+		client:  client,
+		filter:  filter,
+		timeout: config.Timeout,
 	}, nil
 }
 
-func (c *ContactGenerator) Generate(ctx context.Context, mail string, images []ImageData) (*helper.Contact, error) {
-	imagesData := make([]genai.Part, len(images)+2)
-	for i, image := range images {
-		decoded := make([]byte, base64.URLEncoding.DecodedLen(len(image.Data)))
-		n, err := base64.URLEncoding.Decode(decoded, image.Data)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode image data: %w", err)
-		}
-		if n == 0 {
-			return nil, fmt.Errorf("decoded image data is empty")
+func (c *ContactGenerator) Generate(ctx context.Context, data MailData) (*helper.Contact, error) {
+	prompt := buildPrompt(c.filter, data)
+	parts := make([]genai.Part, 0, len(prompt))
+	for _, part := range prompt {
+		switch {
+		case part.Image != nil:
+			parts = append(parts, genai.ImageData(strings.TrimPrefix(part.Image.Type, "image/"), part.Image.Data))
+		case part.Document != nil && len(part.Document.Data) > 0:
+			parts = append(parts, genai.Blob{MIMEType: part.Document.Type, Data: part.Document.Data})
+		case part.Document != nil:
+			parts = append(parts, genai.Text(documentTextPrompt+part.Document.Text))
+		default:
+			parts = append(parts, genai.Text(part.Text))
 		}
-		imagesData[i] = genai.ImageData(
-			image.Type,
-			decoded[:n],
-		)
 	}
-
-	imagesData[len(images)] = genai.Text("Extract the sender data, utilizing the data from the top of the mail, aswell as the footer, from this mail: \n" +
-		mail + "\n" +
-		"Be very sure of the data you extract, if data is missing, do not make it up, but return an empty string instead, if the email or phone is different between the top and the footer, return the email or phone from the footer, be sure to include the data if the mail contains it",
-	)
-	imagesData[len(images)+1] = genai.Text("If images are present, use them to extract the data, if the images are not clear, return an empty string instead of making up data")
-	resp, err := c.model.GenerateContent(ctx,
-		imagesData...,
-	)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	resp, err := c.model.GenerateContent(ctx, parts...)
 	if err != nil {
 		return nil, err
 	}
@@ -86,6 +137,20 @@ func (c *ContactGenerator) Generate(ctx context.Context, mail string, images []I
 	return nil, fmt.Errorf("no valid response found")
 }
 
+func originalSenderPrompt(forwarder string, original *mail_parser.ForwardedMessage) string {
+	prompt := "This mail was forwarded by " + forwarder + ", do not extract the forwarder's data. " +
+		"The contact to extract is the original sender of the forwarded message:\n" +
+		"Name: " + original.Name + "\n" +
+		"Email: " + original.Email + "\n"
+	if original.Date != "" {
+		prompt += "Date: " + original.Date + "\n"
+	}
+	if original.Subject != "" {
+		prompt += "Subject: " + original.Subject + "\n"
+	}
+	return prompt + "Use the signature of the original sender to fill in the remaining data"
+}
+
 func (c *ContactGenerator) Close() error {
 	return c.client.Close()
 }
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -38,6 +39,13 @@ type Token struct {
 	Expiry       time.Time
 }
 
+type MailboxState struct {
+	Email           string `gorm:"primaryKey"`
+	HistoryId       uint64
+	WatchExpiration time.Time
+	LastSyncedAt    time.Time
+}
+
 func NewDatabase(ctx context.Context, config DatabaseConfig) (*Database, error) {
 	db, err := gorm.Open(postgres.Open(
 		"host="+config.Host+" user="+config.User+" password="+config.Password+" dbname="+config.Database+" port=5432 sslmode=disable",
@@ -47,7 +55,7 @@ func NewDatabase(ctx context.Context, config DatabaseConfig) (*Database, error)
 	if err != nil {
 		return nil, err
 	}
-	db.AutoMigrate(&Token{})
+	db.AutoMigrate(&Token{}, &MailboxState{})
 	return &Database{db: db}, nil
 }
 
@@ -70,3 +78,19 @@ func (d *Database) GetToken(ctx context.Context, email string) (*Token, error) {
 	}
 	return &token, nil
 }
+
+func (d *Database) GetMailboxState(ctx context.Context, email string) (*MailboxState, error) {
+	var state MailboxState
+	err := d.db.WithContext(ctx).Model(&MailboxState{}).Where("email = ?", email).First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (d *Database) SaveMailboxState(ctx context.Context, state MailboxState) error {
+	return d.db.WithContext(ctx).Save(&state).Error
+}
@@ -29,11 +29,7 @@ type TokenWithEmail struct {
 	Email string
 }
 
-func NewAuth(ctx context.Context, config database.DatabaseConfig) (*Auth, error) {
-	db, err := database.NewDatabase(ctx, config)
-	if err != nil {
-		return nil, err
-	}
+func NewAuth(ctx context.Context, db *database.Database) (*Auth, error) {
 	return &Auth{
 		db:            db,
 		recieverEmail: "",
@@ -76,8 +72,12 @@ func (a *Auth) HandleAuthCode(ctx context.Context, authConfig *AuthConfig, code
 	return a.SaveToken(ctx, authConfig, tok)
 }
 
+func (a *Auth) SetRecieverEmail(email string) {
+	a.recieverEmail = email
+}
+
 func (a *Auth) StartAuth(ctx context.Context, authConfig *AuthConfig) {
-	a.recieverEmail = authConfig.Email
+	a.SetRecieverEmail(authConfig.Email)
 	if _, err := a.TokenFromDb(ctx, authConfig); err != nil {
 		url, err := a.GetUrl(ctx, *authConfig)
 		if err != nil {
@@ -1,9 +1,61 @@
 package helper
 
+import "strings"
+
 type Contact struct {
-	Name         string `json:"name"`
-	Surname      string `json:"surname"`
-	Email        string `json:"email"`
-	Phone        string `json:"phone"`
-	Organization string `json:"organization"`
+	Name         string       `json:"name"`
+	Surname      string       `json:"surname"`
+	Email        string       `json:"email"`
+	Phone        string       `json:"phone"`
+	Organization string       `json:"organization"`
+	Title        string       `json:"title"`
+	Department   string       `json:"department"`
+	Address      string       `json:"address"`
+	Website      string       `json:"website"`
+	LinkedIn     string       `json:"linkedin"`
+	Emails       []TypedValue `json:"emails"`
+	Phones       []TypedValue `json:"phones"`
+}
+
+type TypedValue struct {
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+const (
+	TypeWork   = "work"
+	TypeHome   = "home"
+	TypeMobile = "mobile"
+	TypeFax    = "fax"
+	TypeOther  = "other"
+)
+
+func (c *Contact) IsEmpty() bool {
+	return c.Name == "" && c.Surname == "" && c.Email == "" && c.Phone == "" && c.Organization == "" &&
+		c.Title == "" && c.Department == "" && c.Address == "" && c.Website == "" && c.LinkedIn == "" &&
+		len(c.Emails) == 0 && len(c.Phones) == 0
+}
+
+func (c *Contact) AllEmails() []TypedValue {
+	return withPrimary(c.Emails, c.Email)
+}
+
+func (c *Contact) AllPhones() []TypedValue {
+	return withPrimary(c.Phones, c.Phone)
+}
+
+func withPrimary(values []TypedValue, primary string) []TypedValue {
+	all := make([]TypedValue, 0, len(values)+1)
+	found := false
+	for _, value := range values {
+		if strings.TrimSpace(value.Value) == "" {
+			continue
+		}
+		found = found || strings.EqualFold(value.Value, primary)
+		all = append(all, value)
+	}
+	if primary != "" && !found {
+		all = append([]TypedValue{{Value: primary}}, all...)
+	}
+	return all
 }
@@ -0,0 +1,350 @@
+package imap_reciever
+
+import (
+	"MailContactUtilty/helper"
+	"MailContactUtilty/mail_parser"
+	"MailContactUtilty/mail_source"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"google.golang.org/api/gmail/v1"
+)
+
+type ImapReciever struct {
+	Email    string
+	config   ImapRecieverConfig
+	messages map[string]*gmail.Message
+	attempts map[uint32]int
+	unmarked map[uint32]bool
+	mu       sync.Mutex
+}
+
+type ImapRecieverConfig struct {
+	Addr     string
+	Username string
+	Password string
+	Mailbox  string
+	Insecure bool
+	SmtpAddr string
+}
+
+const (
+	retryDelay          = 5 * time.Second
+	idleLogoutPeriod    = 25 * time.Minute
+	failedRetryInterval = time.Minute
+	maxMessageAttempts  = 5
+)
+
+func NewImapReciever(email string, config ImapRecieverConfig) (*ImapReciever, error) {
+	if config.Addr == "" {
+		return nil, fmt.Errorf("imap address is not set")
+	}
+	if config.Mailbox == "" {
+		config.Mailbox = imap.InboxName
+	}
+	return &ImapReciever{
+		Email:    email,
+		config:   config,
+		messages: map[string]*gmail.Message{},
+		attempts: map[uint32]int{},
+		unmarked: map[uint32]bool{},
+	}, nil
+}
+
+func (ir *ImapReciever) ListenForEmails(ctx context.Context, target chan<- *mail_source.Delivery) error {
+	for {
+		err := ir.listen(ctx, target)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("IMAP listen error: %v, retrying in %v", err, retryDelay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+func (ir *ImapReciever) listen(ctx context.Context, target chan<- *mail_source.Delivery) error {
+	updates := make(chan client.Update, 16)
+	c, err := ir.connect(updates)
+	if err != nil {
+		return err
+	}
+	defer c.Logout()
+
+	changed := make(chan struct{}, 1)
+	go func() {
+		for {
+			select {
+			case <-updates:
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			case <-c.LoggedOut():
+				return
+			}
+		}
+	}()
+
+	log.Printf("Starting to receive messages from %s...", ir.config.Mailbox)
+	for {
+		failed, err := ir.fetchUnseen(ctx, c, target)
+		if err != nil {
+			return err
+		}
+		var retry <-chan time.Time
+		if failed > 0 {
+			log.Printf("%d messages not processed, retrying in %v", failed, failedRetryInterval)
+			retry = time.After(failedRetryInterval)
+		}
+
+		stop := make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			done <- c.Idle(stop, &client.IdleOptions{LogoutTimeout: idleLogoutPeriod})
+		}()
+		select {
+		case <-ctx.Done():
+			close(stop)
+			<-done
+			return ctx.Err()
+		case <-changed:
+			close(stop)
+			if err := <-done; err != nil {
+				return err
+			}
+		case <-retry:
+			close(stop)
+			if err := <-done; err != nil {
+				return err
+			}
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (ir *ImapReciever) connect(updates chan client.Update) (*client.Client, error) {
+	var c *client.Client
+	var err error
+	if ir.config.Insecure {
+		c, err = client.Dial(ir.config.Addr)
+	} else {
+		c, err = client.DialTLS(ir.config.Addr, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to imap server: %w", err)
+	}
+	c.Updates = updates
+	if err := c.Login(ir.config.Username, ir.config.Password); err != nil {
+		c.Logout()
+		return nil, fmt.Errorf("unable to log in to imap server: %w", err)
+	}
+	if _, err := c.Select(ir.config.Mailbox, false); err != nil {
+		c.Logout()
+		return nil, fmt.Errorf("unable to select mailbox %s: %w", ir.config.Mailbox, err)
+	}
+	return c, nil
+}
+
+func (ir *ImapReciever) withClient(fn func(c *client.Client) error) error {
+	c, err := ir.connect(nil)
+	if err != nil {
+		return err
+	}
+	defer c.Logout()
+	return fn(c)
+}
+
+func (ir *ImapReciever) fetchUnseen(ctx context.Context, c *client.Client, target chan<- *mail_source.Delivery) (int, error) {
+	criteria := imap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{imap.SeenFlag}
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return 0, fmt.Errorf("error searching messages: %w", err)
+	}
+	failed := 0
+	for _, uid := range uids {
+		if ir.unmarked[uid] {
+			if err := ir.markSeen(c, uid); err != nil {
+				return failed, err
+			}
+			delete(ir.unmarked, uid)
+			continue
+		}
+		if ir.attempts[uid] >= maxMessageAttempts {
+			continue
+		}
+		msg, err := ir.fetchMessage(c, uid)
+		if err != nil {
+			if ir.messageFailed(uid, fmt.Errorf("error fetching message details: %w", err)) {
+				failed++
+			}
+			continue
+		}
+
+		log.Printf("New email received - Subject: %s, From: %s",
+			getHeader(msg.Payload.Headers, "Subject"),
+			getHeader(msg.Payload.Headers, "From"))
+
+		if err := mail_source.Deliver(ctx, target, msg); err != nil {
+			if ctx.Err() != nil {
+				return failed, ctx.Err()
+			}
+			if ir.messageFailed(uid, fmt.Errorf("message %s not processed: %w", msg.Id, err)) {
+				failed++
+			}
+			continue
+		}
+		delete(ir.attempts, uid)
+
+		if err := ir.markSeen(c, uid); err != nil {
+			ir.unmarked[uid] = true
+			return failed, err
+		}
+	}
+	return failed, nil
+}
+
+func (ir *ImapReciever) markSeen(c *client.Client, uid uint32) error {
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uid)
+	if err := c.UidStore(seqSet, imap.FormatFlagsOp(imap.AddFlags, true), []interface{}{imap.SeenFlag}, nil); err != nil {
+		log.Printf("Unable to mark message %d as seen: %v", uid, err)
+		return fmt.Errorf("unable to mark message %d as seen: %w", uid, err)
+	}
+	ir.mu.Lock()
+	delete(ir.messages, strconv.FormatUint(uint64(uid), 10))
+	ir.mu.Unlock()
+	return nil
+}
+
+func (ir *ImapReciever) messageFailed(uid uint32, err error) bool {
+	if !mail_source.Retryable(err) {
+		log.Printf("Skipping message %d, it cannot be processed: %v", uid, err)
+		ir.attempts[uid] = maxMessageAttempts
+		return false
+	}
+	ir.attempts[uid]++
+	if ir.attempts[uid] >= maxMessageAttempts {
+		log.Printf("Skipping message %d after %d attempts: %v", uid, ir.attempts[uid], err)
+		return false
+	}
+	log.Printf("Message %d not processed, leaving unseen: %v", uid, err)
+	return true
+}
+
+func (ir *ImapReciever) fetchMessage(c *client.Client, uid uint32) (*gmail.Message, error) {
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uid)
+	section := &imap.BodySectionName{Peek: true}
+	messages := make(chan *imap.Message, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(seqSet, []imap.FetchItem{section.FetchItem()}, messages)
+	}()
+	var raw []byte
+	for fetched := range messages {
+		body := fetched.GetBody(section)
+		if body == nil {
+			continue
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		raw = data
+	}
+	if err := <-done; err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("message %d not found", uid)
+	}
+
+	msg, err := mail_parser.ParseRaw(strconv.FormatUint(uint64(uid), 10), raw)
+	if err != nil {
+		return nil, err
+	}
+	ir.mu.Lock()
+	ir.messages[msg.Id] = msg
+	ir.mu.Unlock()
+	return msg, nil
+}
+
+func (ir *ImapReciever) GetMessage(ctx context.Context, id string) (*gmail.Message, error) {
+	ir.mu.Lock()
+	msg, ok := ir.messages[id]
+	ir.mu.Unlock()
+	if ok {
+		return msg, nil
+	}
+	uid, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message id: %s", id)
+	}
+	err = ir.withClient(func(c *client.Client) error {
+		msg, err = ir.fetchMessage(c, uint32(uid))
+		return err
+	})
+	if err != nil {
+		log.Printf("Unable to retrieve message: %v", err)
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (ir *ImapReciever) GetAttachment(ctx context.Context, messageId, attachmentId string) (*gmail.MessagePartBody, error) {
+	msg, err := ir.GetMessage(ctx, messageId)
+	if err != nil {
+		return nil, err
+	}
+	body := mail_parser.FindAttachment(msg, attachmentId)
+	if body == nil {
+		return nil, fmt.Errorf("attachment %s not found in message %s", attachmentId, messageId)
+	}
+	return body, nil
+}
+
+func (ir *ImapReciever) MarkProcessed(ctx context.Context, id string) error {
+	uid, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid message id: %s", id)
+	}
+	return ir.withClient(func(c *client.Client) error {
+		return ir.markSeen(c, uint32(uid))
+	})
+}
+
+func (ir *ImapReciever) Reply(ctx context.Context, id string, contacts []*helper.Contact, originalMsg *gmail.Message, sender string) error {
+	address, err := mail_parser.ParseAddress(sender)
+	if err != nil {
+		return fmt.Errorf("invalid reply address: %v", err)
+	}
+	relay := mail_source.SmtpRelay{Addr: ir.config.SmtpAddr, Username: ir.config.Username, Password: ir.config.Password}
+	if err := relay.Send(ir.Email, []string{address.Address}, mail_source.BuildReply(ir.Email, contacts, originalMsg, sender)); err != nil {
+		return fmt.Errorf("unable to send reply: %v", err)
+	}
+	return nil
+}
+
+func getHeader(headers []*gmail.MessagePartHeader, name string) string {
+	for _, header := range headers {
+		if header.Name == name {
+			return header.Value
+		}
+	}
+	return ""
+}
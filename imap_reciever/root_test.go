@@ -0,0 +1,147 @@
+package imap_reciever
+
+import (
+	"MailContactUtilty/mail_parser"
+	"MailContactUtilty/mail_source"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/server"
+)
+
+func startImapServer(t *testing.T) string {
+	t.Helper()
+	srv := server.New(memory.New())
+	srv.AllowInsecureAuth = true
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return l.Addr().String()
+}
+
+func testMessage(subject string) []byte {
+	return []byte("From: Jan Kowalski <jan@example.com>\r\n" +
+		"To: contacts@example.com\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: multipart/mixed; boundary=outer\r\n\r\n" +
+		"--outer\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n\r\n" +
+		"Hi,\r\nJan\r\n" +
+		"--outer\r\n" +
+		"Content-Type: text/vcard\r\n" +
+		"Content-Disposition: attachment; filename=\"jan.vcf\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" +
+		base64.StdEncoding.EncodeToString([]byte("BEGIN:VCARD\r\nFN:Jan\r\nEND:VCARD\r\n")) + "\r\n" +
+		"--outer--\r\n")
+}
+
+func unseenSubjects(t *testing.T, c *client.Client) []string {
+	t.Helper()
+	criteria := imap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{imap.SeenFlag}
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uids) == 0 {
+		return nil
+	}
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uids...)
+	messages := make(chan *imap.Message, len(uids))
+	if err := c.UidFetch(seqSet, []imap.FetchItem{imap.FetchEnvelope}, messages); err != nil {
+		t.Fatal(err)
+	}
+	var subjects []string
+	for msg := range messages {
+		subjects = append(subjects, msg.Envelope.Subject)
+	}
+	slices.Sort(subjects)
+	return subjects
+}
+
+func TestFetchUnseen(t *testing.T) {
+	addr := startImapServer(t)
+	reciever, err := NewImapReciever("contacts@example.com", ImapRecieverConfig{Addr: addr, Username: "username", Password: "password", Insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := reciever.connect(nil)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer c.Logout()
+	for _, subject := range []string{"Accepted", "Retry", "Rejected"} {
+		if err := c.Append(imap.InboxName, nil, time.Now(), bytes.NewBuffer(testMessage(subject))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	target := make(chan *mail_source.Delivery)
+	var delivered []string
+	var acceptedId, attachmentId string
+	go func() {
+		for delivery := range target {
+			subject := getHeader(delivery.Message.Payload.Headers, "Subject")
+			delivered = append(delivered, subject)
+			switch subject {
+			case "Accepted":
+				acceptedId = delivery.Message.Id
+				attachmentId = mail_parser.Walk(delivery.Message.Payload).Attachments[0].Body.AttachmentId
+				delivery.Settle(nil)
+			case "Retry":
+				delivery.Settle(context.DeadlineExceeded)
+			default:
+				delivery.Settle(errors.New("invalid contact response"))
+			}
+		}
+	}()
+
+	failed, err := reciever.fetchUnseen(ctx, c, target)
+	if err != nil {
+		t.Fatalf("fetchUnseen: %v", err)
+	}
+	if failed != 1 {
+		t.Errorf("fetchUnseen reported %d failed messages, want 1", failed)
+	}
+	if want := []string{"Accepted", "Retry", "Rejected"}; !slices.Equal(delivered, want) {
+		t.Errorf("delivered %v, want %v", delivered, want)
+	}
+	if got, want := unseenSubjects(t, c), []string{"Rejected", "Retry"}; !slices.Equal(got, want) {
+		t.Errorf("unseen messages %v, want %v", got, want)
+	}
+
+	delivered = nil
+	if _, err := reciever.fetchUnseen(ctx, c, target); err != nil {
+		t.Fatalf("fetchUnseen: %v", err)
+	}
+	if want := []string{"Retry"}; !slices.Equal(delivered, want) {
+		t.Errorf("second pass delivered %v, want %v", delivered, want)
+	}
+	close(target)
+
+	body, err := reciever.GetAttachment(ctx, acceptedId, attachmentId)
+	if err != nil {
+		t.Fatalf("GetAttachment: %v", err)
+	}
+	data, err := base64.URLEncoding.DecodeString(body.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("FN:Jan")) {
+		t.Errorf("attachment = %q", data)
+	}
+}
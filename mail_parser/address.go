@@ -0,0 +1,59 @@
+package mail_parser
+
+import (
+	"MailContactUtilty/signature_detector"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+type MessageAddresses struct {
+	From    *mail.Address
+	Sender  *mail.Address
+	ReplyTo []*mail.Address
+}
+
+var addressParser = mail.AddressParser{WordDecoder: &mime.WordDecoder{CharsetReader: CharsetReader}}
+
+func ParseAddress(value string) (*mail.Address, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("empty address")
+	}
+	if list, err := addressParser.ParseList(value); err == nil && len(list) > 0 {
+		return list[0], nil
+	}
+	if email := signature_detector.EmailRegexp.FindString(value); email != "" {
+		name := strings.Trim(decodeHeader(strings.Replace(value, email, "", 1)), ` "'<>()`)
+		return &mail.Address{Name: name, Address: email}, nil
+	}
+	return nil, fmt.Errorf("no address found in %q", value)
+}
+
+func ParseAddressList(value string) []*mail.Address {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	if list, err := addressParser.ParseList(value); err == nil {
+		return list
+	}
+	if address, err := ParseAddress(value); err == nil {
+		return []*mail.Address{address}
+	}
+	return nil
+}
+
+func GetAddresses(headers []*gmail.MessagePartHeader) MessageAddresses {
+	var addresses MessageAddresses
+	addresses.From, _ = ParseAddress(GetHeader(headers, "From"))
+	addresses.Sender, _ = ParseAddress(GetHeader(headers, "Sender"))
+	addresses.ReplyTo = ParseAddressList(GetHeader(headers, "Reply-To"))
+	return addresses
+}
+
+func SameAddress(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
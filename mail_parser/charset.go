@@ -0,0 +1,59 @@
+package mail_parser
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"google.golang.org/api/gmail/v1"
+)
+
+func PartText(part *gmail.MessagePart, data []byte) (string, error) {
+	return DecodeCharset(data, GetHeader(part.Headers, "Content-Type"))
+}
+
+func DecodeCharset(data []byte, contentType string) (string, error) {
+	_, params, _ := mime.ParseMediaType(contentType)
+	charset := strings.ToLower(strings.TrimSpace(params["charset"]))
+	if charset == "" || charset == "utf-8" || charset == "utf8" || charset == "us-ascii" {
+		if !utf8.Valid(data) {
+			return strings.ToValidUTF8(string(data), "�"), nil
+		}
+		return string(data), nil
+	}
+	enc, err := lookupEncoding(charset)
+	if err != nil {
+		log.Printf("Unable to decode %s text, keeping the valid UTF-8: %v", charset, err)
+		return strings.ToValidUTF8(string(data), "�"), nil
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode %s text: %w", charset, err)
+	}
+	return string(decoded), nil
+}
+
+func CharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := lookupEncoding(strings.ToLower(strings.TrimSpace(charset)))
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+func lookupEncoding(charset string) (encoding.Encoding, error) {
+	if enc, err := htmlindex.Get(charset); err == nil {
+		return enc, nil
+	}
+	enc, err := ianaindex.MIME.Encoding(charset)
+	if err != nil || enc == nil {
+		return nil, fmt.Errorf("unsupported charset: %s", charset)
+	}
+	return enc, nil
+}
@@ -0,0 +1,29 @@
+package mail_parser
+
+import "testing"
+
+func TestDecodeCharset(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		want        string
+	}{
+		{name: "no charset", data: []byte("Jan Kowalski"), contentType: "text/plain", want: "Jan Kowalski"},
+		{name: "invalid utf-8", data: []byte("Jan \xff Kowalski"), contentType: "text/plain; charset=utf-8", want: "Jan � Kowalski"},
+		{name: "iso-8859-2", data: []byte("Pawe\xb3 \xa3\xf3d\xbc"), contentType: "text/plain; charset=ISO-8859-2", want: "Paweł Łódź"},
+		{name: "windows-1250", data: []byte("Pawe\xb3 \xa3\xf3d\x9f"), contentType: "text/plain; charset=\"windows-1250\"", want: "Paweł Łódź"},
+		{name: "unknown charset", data: []byte("Jan Kowalski \xff"), contentType: "text/plain; charset=x-unknown", want: "Jan Kowalski �"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := DecodeCharset(test.data, test.contentType)
+			if err != nil {
+				t.Fatalf("DecodeCharset: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("DecodeCharset = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package mail_parser
+
+import (
+	"MailContactUtilty/signature_detector"
+	"regexp"
+	"strings"
+)
+
+type CleanedBody struct {
+	Text      string
+	Signature string
+}
+
+const outlookHeaderLines = 5
+
+var (
+	replyMarkerRegexp   = regexp.MustCompile(`(?i)^[ \t]*(?:On\s.+\swrote:|(?:W dniu|Dnia)\s.+\s(?:napisał|napisała|napisał\(a\)|pisze):|-{2,}\s*(?:Original Message|Wiadomość oryginalna|Oryginalna wiadomość)\s*-{2,})[ \t]*$`)
+	replyMarkerStart    = regexp.MustCompile(`(?i)^[ \t]*(?:On\s|W dniu\s|Dnia\s)`)
+	outlookFromRegexp   = regexp.MustCompile(`(?i)^[ \t]*\*?(?:From|Od):\*?\s+\S`)
+	outlookHeaderRegexp = regexp.MustCompile(`(?i)^[ \t]*\*?(?:Sent|Date|Wysłano|Wysłane|Data|Subject|Temat|To|Do|Cc|DW):\*?\s`)
+	quotedLineRegexp    = regexp.MustCompile(`^[ \t]*>`)
+	sentFromRegexp      = regexp.MustCompile(`(?i)^[ \t]*(?:Sent from my \S.*|Sent from (?:Mail|Outlook|Yahoo Mail|Gmail) for \S.*|Get Outlook for \S.*|Wysłane z (?:mojego )?\S.*|Wysłano z (?:mojego )?\S.*)$`)
+	disclaimerRegexp    = regexp.MustCompile(`(?is)(?:this (?:e-?mail|message|communication)(?: and any (?:files|attachments)[^.]*)? (?:is|are|may be|may contain|contains?)[^.]*(?:confidential|privileged)|intended (?:solely |only )?for the (?:use of the )?(?:addressee|recipient|individual|person)|if you (?:have )?received this (?:e-?mail|message|communication) (?:in error|by mistake)|please consider the environment before printing|ta wiadomość (?:jest poufna|może zawierać|i jej załączniki)|treść tej wiadomości (?:jest|może)|niniejsza wiadomość (?:jest|może|oraz)|jeśli nie jest pan(?:i|em)? adresatem|zanim wydrukujesz)`)
+)
+
+func CleanBody(text string) CleanedBody {
+	cleaned, _ := CleanBodyWithImages(text, nil)
+	return cleaned
+}
+
+func CleanBodyWithImages(text string, images []InlineImage) (CleanedBody, []InlineImage) {
+	imageLines := make([]int, len(images))
+	imageColumns := make([]int, len(images))
+	for i, image := range images {
+		offset := min(max(image.Offset, 0), len(text))
+		imageLines[i] = strings.Count(text[:offset], "\n")
+		imageColumns[i] = offset - strings.LastIndexByte(text[:offset], '\n') - 1
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := unquote(strings.Split(text, "\n"))
+	kept := make([]int, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		if isReplyMarker(lines, i) {
+			break
+		}
+		if quotedLineRegexp.MatchString(lines[i]) || sentFromRegexp.MatchString(lines[i]) {
+			continue
+		}
+		kept = append(kept, i)
+	}
+
+	var sb strings.Builder
+	lineOffsets := map[int]int{}
+	for start := 0; start < len(kept); {
+		if strings.TrimSpace(lines[kept[start]]) == "" {
+			start++
+			continue
+		}
+		end := start
+		for end < len(kept) && strings.TrimSpace(lines[kept[end]]) != "" {
+			end++
+		}
+		paragraph := make([]string, 0, end-start)
+		for _, i := range kept[start:end] {
+			paragraph = append(paragraph, lines[i])
+		}
+		if !disclaimerRegexp.MatchString(strings.Join(paragraph, "\n")) {
+			if sb.Len() > 0 {
+				sb.WriteString("\n\n")
+			}
+			for j, i := range kept[start:end] {
+				line := lines[i]
+				if sb.Len() == 0 {
+					line = strings.TrimLeft(line, " \t")
+				}
+				if j == end-start-1 {
+					line = strings.TrimRight(line, " \t")
+				} else {
+					line += "\n"
+				}
+				lineOffsets[i] = sb.Len()
+				sb.WriteString(line)
+			}
+		}
+		start = end
+	}
+	cleaned := CleanedBody{Text: sb.String()}
+	if signature := signature_detector.Detect(cleaned.Text); signature != nil {
+		cleaned.Signature = signature.Text
+	}
+
+	var placed []InlineImage
+	for i, image := range images {
+		offset, ok := lineOffsets[imageLines[i]]
+		if !ok {
+			continue
+		}
+		lineEnd := strings.IndexByte(cleaned.Text[offset:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(cleaned.Text) - offset
+		}
+		image.Offset = offset + min(imageColumns[i], lineEnd)
+		placed = append(placed, image)
+	}
+	return cleaned, placed
+}
+
+func unquote(lines []string) []string {
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" && !quotedLineRegexp.MatchString(line) {
+			return lines
+		}
+	}
+	unquoted := make([]string, len(lines))
+	for i, line := range lines {
+		line = strings.TrimLeft(line, " \t")
+		line = strings.TrimPrefix(line, ">")
+		unquoted[i] = strings.TrimPrefix(line, " ")
+	}
+	return unquoted
+}
+
+func isReplyMarker(lines []string, i int) bool {
+	if replyMarkerRegexp.MatchString(lines[i]) {
+		return true
+	}
+	if i+1 < len(lines) && replyMarkerStart.MatchString(lines[i]) && replyMarkerRegexp.MatchString(lines[i]+" "+strings.TrimSpace(lines[i+1])) {
+		return true
+	}
+	if !outlookFromRegexp.MatchString(lines[i]) {
+		return false
+	}
+	headers := 0
+	for j := i + 1; j < len(lines) && j <= i+outlookHeaderLines; j++ {
+		if outlookHeaderRegexp.MatchString(lines[j]) {
+			headers++
+		}
+	}
+	return headers >= 2
+}
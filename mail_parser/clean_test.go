@@ -0,0 +1,65 @@
+package mail_parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCleanBody(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		want      string
+		signature string
+	}{
+		{
+			name: "short mail without signature",
+			text: "Hi\n\nJan\n+48 600 100 200",
+			want: "Hi\n\nJan\n+48 600 100 200",
+		},
+		{
+			name:      "reply with quoted thread",
+			text:      "Please add me.\n\nBest regards,\nAnna Nowak\nSales Manager\n+48 600 100 200\nanna@acme.pl\n\nOn Mon, 1 Jan 2024 at 10:00, Jan <jan@example.com> wrote:\n> Who are you?\n> --\n> Jan Kowalski\n> +48 700 200 300",
+			want:      "Please add me.\n\nBest regards,\nAnna Nowak\nSales Manager\n+48 600 100 200\nanna@acme.pl",
+			signature: "Best regards,\nAnna Nowak\nSales Manager\n+48 600 100 200\nanna@acme.pl",
+		},
+		{
+			name:      "delimited signature with disclaimer",
+			text:      "Hello\n\n-- \nAnna Nowak\n+48 600 100 200\n\nThis email is confidential and intended only for the recipient.\n\nSent from my iPhone",
+			want:      "Hello\n\n-- \nAnna Nowak\n+48 600 100 200",
+			signature: "Anna Nowak\n+48 600 100 200",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cleaned := CleanBody(test.text)
+			if cleaned.Text != test.want {
+				t.Errorf("Text = %q, want %q", cleaned.Text, test.want)
+			}
+			if cleaned.Signature != test.signature {
+				t.Errorf("Signature = %q, want %q", cleaned.Signature, test.signature)
+			}
+		})
+	}
+}
+
+func TestCleanBodyWithImages(t *testing.T) {
+	src := `<p>Please add me.</p>
+<p>Best regards,<br>Anna Nowak<br>Sales Manager<br>+48 600 100 200<br>anna@acme.pl<br><img src="cid:anna-logo"></p>
+<div>On Mon, 1 Jan 2024 at 10:00, Jan &lt;jan@example.com&gt; wrote:</div>
+<blockquote><p>Who are you?</p><p>Jan Kowalski<br>CEO<br>+48 700 200 300<br>jan@example.com<br><img src="cid:jan-logo"></p></blockquote>`
+	text, _, images := HtmlToTextWithImages(src)
+	if len(images) != 2 {
+		t.Fatalf("found %d images, want 2", len(images))
+	}
+	cleaned, kept := CleanBodyWithImages(text, images)
+	if len(kept) != 1 || kept[0].ContentId != "anna-logo" {
+		t.Fatalf("kept images = %+v, want only anna-logo", kept)
+	}
+	if start := SignatureStart(cleaned.Text); kept[0].Offset < start {
+		t.Errorf("logo offset %d is before the signature at %d in %q", kept[0].Offset, start, cleaned.Text)
+	}
+	if cleaned.Signature == "" || !strings.Contains(cleaned.Signature, "Anna Nowak") {
+		t.Errorf("Signature = %q", cleaned.Signature)
+	}
+}
@@ -0,0 +1,137 @@
+package mail_parser
+
+import (
+	"MailContactUtilty/signature_detector"
+	"encoding/base64"
+	"mime"
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+type ForwardedMessage struct {
+	From    string
+	Name    string
+	Email   string
+	Date    string
+	Subject string
+	Body    string
+	Note    string
+}
+
+var (
+	forwardMarkerRegexp = regexp.MustCompile(`(?im)^[ \t>]*(?:-{2,}\s*(?:Forwarded message|Original Message|Wiadomość przekazana dalej|Wiadomość przekazana|Wiadomość oryginalna|Oryginalna wiadomość)\s*-{2,}|Begin forwarded message:|Początek przekazywanej wiadomości:|_{10,})[ \t]*$`)
+	forwardHeaderRegexp = regexp.MustCompile(`^[ \t>]*\*?(From|Od|Sent|Wysłano|Wysłane|Date|Data|Subject|Temat|To|Do|Cc|DW|Reply-To)\*?:\s*(.*)$`)
+	mailtoRegexp        = regexp.MustCompile(`^(.*?)\s*\[mailto:([^\]]+)\]`)
+)
+
+func ParseForwarded(text string) *ForwardedMessage {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for _, loc := range forwardMarkerRegexp.FindAllStringIndex(text, -1) {
+		if fwd := parseForwardedHeaders(text[loc[1]:]); fwd != nil {
+			fwd.Note = strings.TrimSpace(text[:loc[0]])
+			return fwd
+		}
+	}
+	return nil
+}
+
+func ParseForwardedAttachment(raw []byte) (*ForwardedMessage, error) {
+	msg, err := ParseRaw("", raw)
+	if err != nil {
+		return nil, err
+	}
+	fwd := &ForwardedMessage{
+		Date:    decodeHeader(GetHeader(msg.Payload.Headers, "Date")),
+		Subject: decodeHeader(GetHeader(msg.Payload.Headers, "Subject")),
+		Body:    InlineBodyText(msg.Payload),
+	}
+	fwd.setFrom(decodeHeader(GetHeader(msg.Payload.Headers, "From")))
+	return fwd, nil
+}
+
+func InlineBodyText(payload *gmail.MessagePart) string {
+	parts := Walk(payload)
+	bodyType := parts.BodyType()
+	text := ""
+	for _, part := range parts.Bodies {
+		if part.MimeType != bodyType || part.Body.Data == "" {
+			continue
+		}
+		data, err := base64.URLEncoding.DecodeString(part.Body.Data)
+		if err != nil {
+			continue
+		}
+		partText, err := PartText(part, data)
+		if err != nil {
+			continue
+		}
+		if bodyType == "text/html" {
+			partText, _ = HtmlToText(partText)
+		}
+		text += partText + "\n"
+	}
+	return text
+}
+
+func parseForwardedHeaders(text string) *ForwardedMessage {
+	lines := strings.Split(text, "\n")
+	fwd := &ForwardedMessage{}
+	i := 0
+	for i < len(lines) && strings.TrimSpace(strings.TrimLeft(lines[i], ">")) == "" {
+		i++
+	}
+	found := false
+	for ; i < len(lines); i++ {
+		matches := forwardHeaderRegexp.FindStringSubmatch(lines[i])
+		if matches == nil {
+			break
+		}
+		found = true
+		value := strings.TrimSpace(matches[2])
+		switch strings.ToLower(matches[1]) {
+		case "from", "od":
+			fwd.setFrom(value)
+		case "sent", "wysłano", "wysłane", "date", "data":
+			fwd.Date = value
+		case "subject", "temat":
+			fwd.Subject = value
+		}
+	}
+	if !found || fwd.From == "" {
+		return nil
+	}
+	fwd.Body = strings.TrimSpace(strings.Join(lines[i:], "\n"))
+	return fwd
+}
+
+func (f *ForwardedMessage) setFrom(value string) {
+	f.From = value
+	if value == "" {
+		return
+	}
+	if address, err := addressParser.Parse(value); err == nil {
+		f.Name, f.Email = address.Name, address.Address
+		return
+	}
+	if matches := mailtoRegexp.FindStringSubmatch(value); matches != nil {
+		f.Name, f.Email = strings.Trim(matches[1], ` "'`), matches[2]
+		return
+	}
+	f.Email = signature_detector.EmailRegexp.FindString(value)
+	name, _, _ := strings.Cut(value, "<")
+	if f.Email != "" {
+		name = strings.Replace(value, f.Email, "", 1)
+	}
+	f.Name = strings.Trim(name, ` "'<>()`)
+}
+
+func decodeHeader(value string) string {
+	decoder := mime.WordDecoder{CharsetReader: CharsetReader}
+	decoded, err := decoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
@@ -0,0 +1,201 @@
+package mail_parser
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type Link struct {
+	Text string
+	Href string
+}
+
+type InlineImage struct {
+	ContentId string
+	Alt       string
+	Offset    int
+}
+
+const imageMarker = '\uE000'
+
+var (
+	blankLinesRegexp = regexp.MustCompile(`\n{3,}`)
+	spacesRegexp     = regexp.MustCompile(`[ \t\f\r\n\x{00a0}]+`)
+)
+
+func HtmlToText(src string) (string, []Link) {
+	text, links, _ := HtmlToTextWithImages(src)
+	return text, links
+}
+
+func HtmlToTextWithImages(src string) (string, []Link, []InlineImage) {
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return src, nil, nil
+	}
+	c := &htmlConverter{}
+	c.walk(doc)
+	var lines []string
+	for _, line := range strings.Split(c.sb.String(), "\n") {
+		trimmed := strings.Trim(line, " |"+string(imageMarker))
+		markers := strings.Repeat(string(imageMarker), strings.Count(line, string(imageMarker))-strings.Count(trimmed, string(imageMarker)))
+		if len(lines) > 0 && trimmed == "" && markers != "" {
+			lines[len(lines)-1] += markers
+			continue
+		}
+		lines = append(lines, markers+trimmed)
+	}
+	text := blankLinesRegexp.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	text = strings.TrimSpace(text)
+
+	var sb strings.Builder
+	images := c.images
+	i := 0
+	for _, r := range text {
+		if r == imageMarker {
+			if i < len(images) {
+				images[i].Offset = sb.Len()
+				i++
+			}
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	text = strings.TrimRight(sb.String(), " \n")
+	trimmed := strings.TrimLeft(text, " \n")
+	for i := range images {
+		images[i].Offset = min(max(images[i].Offset-(len(text)-len(trimmed)), 0), len(trimmed))
+	}
+	return trimmed, c.links, images
+}
+
+type htmlConverter struct {
+	sb     strings.Builder
+	links  []Link
+	images []InlineImage
+}
+
+func (c *htmlConverter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		c.text(n.Data)
+		return
+	case html.ElementNode:
+		switch n.DataAtom {
+		case atom.Script, atom.Style, atom.Head, atom.Title, atom.Noscript:
+			return
+		case atom.Br:
+			c.sb.WriteString("\n")
+			return
+		case atom.Hr:
+			c.newline()
+			c.sb.WriteString("--")
+			c.newline()
+			return
+		case atom.Img:
+			alt := strings.TrimSpace(attr(n, "alt"))
+			if src := strings.TrimSpace(attr(n, "src")); strings.HasPrefix(strings.ToLower(src), "cid:") {
+				c.images = append(c.images, InlineImage{ContentId: src[len("cid:"):], Alt: alt})
+				c.sb.WriteRune(imageMarker)
+			}
+			if alt != "" {
+				c.text("[" + alt + "]")
+			}
+			return
+		case atom.A:
+			c.anchor(n)
+			return
+		case atom.Td, atom.Th:
+			c.children(n)
+			c.sb.WriteString(" | ")
+			return
+		}
+		if isBlock(n.DataAtom) {
+			c.newline()
+			c.children(n)
+			c.newline()
+			return
+		}
+	}
+	c.children(n)
+}
+
+func (c *htmlConverter) children(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.walk(child)
+	}
+}
+
+func (c *htmlConverter) anchor(n *html.Node) {
+	start := c.sb.Len()
+	c.children(n)
+	text := strings.TrimSpace(c.sb.String()[start:])
+	href := strings.TrimSpace(attr(n, "href"))
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "cid:") {
+		return
+	}
+	c.links = append(c.links, Link{Text: text, Href: href})
+
+	lower := strings.ToLower(href)
+	value := href
+	switch {
+	case strings.HasPrefix(lower, "mailto:"):
+		value, _, _ = strings.Cut(href[len("mailto:"):], "?")
+	case strings.HasPrefix(lower, "tel:"):
+		value = href[len("tel:"):]
+	}
+	if !strings.Contains(normalizeLink(text), normalizeLink(value)) {
+		c.text("<" + value + ">")
+	}
+}
+
+func (c *htmlConverter) text(s string) {
+	s = spacesRegexp.ReplaceAllString(s, " ")
+	if s == "" || s == " " && (c.sb.Len() == 0 || strings.HasSuffix(c.sb.String(), "\n")) {
+		return
+	}
+	current := c.sb.String()
+	if len(current) > 0 && !strings.HasSuffix(current, " ") && !strings.HasSuffix(current, "\n") && !strings.HasPrefix(s, " ") {
+		if strings.HasPrefix(s, "<") || strings.HasPrefix(s, "[") {
+			c.sb.WriteString(" ")
+		}
+	}
+	c.sb.WriteString(s)
+}
+
+func (c *htmlConverter) newline() {
+	if c.sb.Len() > 0 && !strings.HasSuffix(c.sb.String(), "\n") {
+		c.sb.WriteString("\n")
+	}
+}
+
+func isBlock(a atom.Atom) bool {
+	switch a {
+	case atom.P, atom.Div, atom.Tr, atom.Table, atom.Tbody, atom.Thead, atom.Li, atom.Ul, atom.Ol,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Blockquote, atom.Pre,
+		atom.Section, atom.Article, atom.Header, atom.Footer, atom.Address, atom.Body:
+		return true
+	}
+	return false
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func normalizeLink(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '(' || r == ')' || r == '.' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(s))
+}
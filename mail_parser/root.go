@@ -0,0 +1,144 @@
+package mail_parser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func ParseRaw(id string, raw []byte) (*gmail.Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse message: %w", err)
+	}
+	payload, err := parsePart("", textproto.MIMEHeader(msg.Header), msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &gmail.Message{
+		Id:           id,
+		ThreadId:     id,
+		Payload:      payload,
+		SizeEstimate: int64(len(raw)),
+		Raw:          base64.URLEncoding.EncodeToString(raw),
+	}, nil
+}
+
+func FindAttachment(msg *gmail.Message, attachmentId string) *gmail.MessagePartBody {
+	if msg == nil {
+		return nil
+	}
+	return findAttachment(msg.Payload, attachmentId)
+}
+
+func findAttachment(part *gmail.MessagePart, attachmentId string) *gmail.MessagePartBody {
+	if part == nil {
+		return nil
+	}
+	if part.Body != nil && part.Body.AttachmentId == attachmentId {
+		return part.Body
+	}
+	for _, child := range part.Parts {
+		if body := findAttachment(child, attachmentId); body != nil {
+			return body
+		}
+	}
+	return nil
+}
+
+func parsePart(partId string, header textproto.MIMEHeader, body io.Reader) (*gmail.MessagePart, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	part := &gmail.MessagePart{
+		PartId:   partId,
+		MimeType: mediaType,
+		Filename: filename(header, params),
+		Headers:  convertHeaders(header),
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		part.Body = &gmail.MessagePartBody{}
+		for i := 0; ; i++ {
+			child, err := reader.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("unable to read part %s: %w", partId, err)
+			}
+			childPart, err := parsePart(childId(partId, i), child.Header, child)
+			if err != nil {
+				return nil, err
+			}
+			part.Parts = append(part.Parts, childPart)
+		}
+		return part, nil
+	}
+
+	data, err := io.ReadAll(decodeTransferEncoding(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode part %s: %w", partId, err)
+	}
+	part.Body = &gmail.MessagePartBody{
+		Data: base64.URLEncoding.EncodeToString(data),
+		Size: int64(len(data)),
+	}
+	if !strings.HasPrefix(mediaType, "text/") || part.Filename != "" {
+		part.Body.AttachmentId = "part:" + partId
+	}
+	return part, nil
+}
+
+func decodeTransferEncoding(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+func childId(partId string, i int) string {
+	if partId == "" {
+		return strconv.Itoa(i)
+	}
+	return partId + "." + strconv.Itoa(i)
+}
+
+func filename(header textproto.MIMEHeader, contentTypeParams map[string]string) string {
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+	return contentTypeParams["name"]
+}
+
+func convertHeaders(header textproto.MIMEHeader) []*gmail.MessagePartHeader {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	headers := make([]*gmail.MessagePartHeader, 0, len(names))
+	for _, name := range names {
+		for _, value := range header[name] {
+			headers = append(headers, &gmail.MessagePartHeader{Name: name, Value: value})
+		}
+	}
+	return headers
+}
@@ -0,0 +1,24 @@
+package mail_parser
+
+import (
+	"MailContactUtilty/signature_detector"
+	"strings"
+)
+
+const signatureFallbackLines = 10
+
+func SignatureStart(text string) int {
+	if signature := signature_detector.Detect(text); signature != nil {
+		return signature.Start
+	}
+	lines := 0
+	for i := len(strings.TrimRight(text, "\n")) - 1; i >= 0; i-- {
+		if text[i] != '\n' {
+			continue
+		}
+		if lines++; lines == signatureFallbackLines {
+			return i + 1
+		}
+	}
+	return 0
+}
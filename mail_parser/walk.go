@@ -0,0 +1,68 @@
+package mail_parser
+
+import (
+	"mime"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+type Parts struct {
+	Bodies      []*gmail.MessagePart
+	Attachments []*gmail.MessagePart
+}
+
+func Walk(payload *gmail.MessagePart) Parts {
+	var parts Parts
+	walk(payload, &parts)
+	return parts
+}
+
+func walk(part *gmail.MessagePart, parts *Parts) {
+	if part == nil {
+		return
+	}
+	if len(part.Parts) > 0 && !strings.EqualFold(part.MimeType, "message/rfc822") {
+		for _, child := range part.Parts {
+			walk(child, parts)
+		}
+		return
+	}
+	if part.Body == nil || (part.Body.Data == "" && part.Body.AttachmentId == "") {
+		return
+	}
+	if IsAttachment(part) {
+		parts.Attachments = append(parts.Attachments, part)
+		return
+	}
+	parts.Bodies = append(parts.Bodies, part)
+}
+
+func (p Parts) BodyType() string {
+	for _, part := range p.Bodies {
+		if part.MimeType == "text/plain" {
+			return "text/plain"
+		}
+	}
+	return "text/html"
+}
+
+func IsAttachment(part *gmail.MessagePart) bool {
+	if !strings.HasPrefix(strings.ToLower(part.MimeType), "text/") {
+		return true
+	}
+	if part.Filename != "" {
+		return true
+	}
+	disposition, _, err := mime.ParseMediaType(GetHeader(part.Headers, "Content-Disposition"))
+	return err == nil && disposition == "attachment"
+}
+
+func GetHeader(headers []*gmail.MessagePartHeader, name string) string {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+	return ""
+}
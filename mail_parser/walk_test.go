@@ -0,0 +1,243 @@
+package mail_parser
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func textPart(mimeType, data string) *gmail.MessagePart {
+	return &gmail.MessagePart{MimeType: mimeType, Body: &gmail.MessagePartBody{Data: data}}
+}
+
+func attachmentPart(mimeType, filename string, headers ...*gmail.MessagePartHeader) *gmail.MessagePart {
+	return &gmail.MessagePart{MimeType: mimeType, Filename: filename, Headers: headers, Body: &gmail.MessagePartBody{AttachmentId: "id-" + filename}}
+}
+
+func multipartPart(mimeType string, children ...*gmail.MessagePart) *gmail.MessagePart {
+	return &gmail.MessagePart{MimeType: mimeType, Body: &gmail.MessagePartBody{}, Parts: children}
+}
+
+func partNames(parts []*gmail.MessagePart) []string {
+	names := make([]string, 0, len(parts))
+	for _, part := range parts {
+		names = append(names, part.MimeType+":"+part.Filename)
+	}
+	return names
+}
+
+func TestWalk(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     *gmail.MessagePart
+		bodies      []string
+		attachments []string
+		bodyType    string
+	}{
+		{
+			name:     "single part body",
+			payload:  textPart("text/plain", "aGk="),
+			bodies:   []string{"text/plain:"},
+			bodyType: "text/plain",
+		},
+		{
+			name:     "empty body",
+			payload:  &gmail.MessagePart{MimeType: "text/plain", Body: &gmail.MessagePartBody{}},
+			bodyType: "text/html",
+		},
+		{
+			name: "mixed alternative related",
+			payload: multipartPart("multipart/mixed",
+				multipartPart("multipart/alternative",
+					textPart("text/plain", "aGk="),
+					multipartPart("multipart/related",
+						textPart("text/html", "PGI-aGk8L2I-"),
+						attachmentPart("image/png", "logo.png", &gmail.MessagePartHeader{Name: "Content-ID", Value: "<logo>"}),
+					),
+				),
+				attachmentPart("application/pdf", "card.pdf"),
+			),
+			bodies:      []string{"text/plain:", "text/html:"},
+			attachments: []string{"image/png:logo.png", "application/pdf:card.pdf"},
+			bodyType:    "text/plain",
+		},
+		{
+			name: "html only with inline image",
+			payload: multipartPart("multipart/related",
+				textPart("text/html", "PGI-aGk8L2I-"),
+				attachmentPart("image/jpeg", "", &gmail.MessagePartHeader{Name: "Content-Disposition", Value: "inline"}),
+			),
+			bodies:      []string{"text/html:"},
+			attachments: []string{"image/jpeg:"},
+			bodyType:    "text/html",
+		},
+		{
+			name: "nested rfc822",
+			payload: multipartPart("multipart/mixed",
+				textPart("text/plain", "aGk="),
+				&gmail.MessagePart{
+					MimeType: "message/rfc822",
+					Body:     &gmail.MessagePartBody{AttachmentId: "id-forwarded"},
+					Parts: []*gmail.MessagePart{
+						multipartPart("multipart/alternative",
+							textPart("text/plain", "b3JpZ2luYWw="),
+							textPart("text/html", "b3JpZ2luYWw="),
+						),
+					},
+				},
+			),
+			bodies:      []string{"text/plain:"},
+			attachments: []string{"message/rfc822:"},
+			bodyType:    "text/plain",
+		},
+		{
+			name: "text attachments",
+			payload: multipartPart("multipart/mixed",
+				textPart("text/plain", "aGk="),
+				attachmentPart("text/plain", "notes.txt"),
+				attachmentPart("text/vcard", "jan.vcf"),
+				&gmail.MessagePart{
+					MimeType: "text/csv",
+					Headers:  []*gmail.MessagePartHeader{{Name: "Content-Disposition", Value: "attachment"}},
+					Body:     &gmail.MessagePartBody{Data: "YSxi"},
+				},
+			),
+			bodies:      []string{"text/plain:"},
+			attachments: []string{"text/plain:notes.txt", "text/vcard:jan.vcf", "text/csv:"},
+			bodyType:    "text/plain",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parts := Walk(test.payload)
+			if got := partNames(parts.Bodies); !slices.Equal(got, test.bodies) {
+				t.Errorf("bodies = %v, want %v", got, test.bodies)
+			}
+			if got := partNames(parts.Attachments); !slices.Equal(got, test.attachments) {
+				t.Errorf("attachments = %v, want %v", got, test.attachments)
+			}
+			if got := parts.BodyType(); got != test.bodyType {
+				t.Errorf("BodyType() = %q, want %q", got, test.bodyType)
+			}
+		})
+	}
+}
+
+func TestWalkRaw(t *testing.T) {
+	raw := "From: Jan <jan@example.com>\r\n" +
+		"Content-Type: multipart/mixed; boundary=outer\r\n\r\n" +
+		"--outer\r\n" +
+		"Content-Type: multipart/alternative; boundary=inner\r\n\r\n" +
+		"--inner\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n\r\n" +
+		"Hi\r\n" +
+		"--inner\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n\r\n" +
+		"<p>Hi</p>\r\n" +
+		"--inner--\r\n" +
+		"--outer\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"Content-Disposition: attachment; filename=\"notes.txt\"\r\n\r\n" +
+		"notes\r\n" +
+		"--outer--\r\n"
+	msg, err := ParseRaw("1", []byte(raw))
+	if err != nil {
+		t.Fatalf("ParseRaw: %v", err)
+	}
+	parts := Walk(msg.Payload)
+	if got, want := partNames(parts.Bodies), []string{"text/plain:", "text/html:"}; !slices.Equal(got, want) {
+		t.Errorf("bodies = %v, want %v", got, want)
+	}
+	if got, want := partNames(parts.Attachments), []string{"text/plain:notes.txt"}; !slices.Equal(got, want) {
+		t.Errorf("attachments = %v, want %v", got, want)
+	}
+}
+
+func TestWalkCorpus(t *testing.T) {
+	tests := []struct {
+		file        string
+		bodies      []string
+		attachments []string
+		bodyType    string
+		text        string
+	}{
+		{
+			file:        "gmail.eml",
+			bodies:      []string{"text/plain:", "text/html:"},
+			attachments: []string{"image/png:logo.png", "application/pdf:card.pdf"},
+			bodyType:    "text/plain",
+			text:        "Sales Manager | ACME Sp. z o.o.",
+		},
+		{
+			file:        "outlook.eml",
+			bodies:      []string{"text/plain:", "text/html:"},
+			attachments: []string{"image/png:image001.png"},
+			bodyType:    "text/plain",
+			text:        "Łukasz Żółkiewski\r\nKierownik sprzedaży",
+		},
+		{
+			file:     "outlook_html_only.eml",
+			bodies:   []string{"text/html:"},
+			bodyType: "text/html",
+			text:     "ul. Świętokrzyska 12",
+		},
+		{
+			file:        "apple_mail.eml",
+			bodies:      []string{"text/plain:", "text/html:"},
+			attachments: []string{"image/jpeg:photo.jpeg"},
+			bodyType:    "text/plain",
+			text:        "Piotr Wiśniewski",
+		},
+		{
+			file:        "forwarded_rfc822.eml",
+			bodies:      []string{"text/plain:"},
+			attachments: []string{"message/rfc822:Business card.eml", "text/vcard:anna.vcf"},
+			bodyType:    "text/plain",
+			text:        "Forwarding the card",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := ParseRaw("1", raw)
+			if err != nil {
+				t.Fatalf("ParseRaw: %v", err)
+			}
+			parts := Walk(msg.Payload)
+			if got := partNames(parts.Bodies); !slices.Equal(got, test.bodies) {
+				t.Errorf("bodies = %v, want %v", got, test.bodies)
+			}
+			if got := partNames(parts.Attachments); !slices.Equal(got, test.attachments) {
+				t.Errorf("attachments = %v, want %v", got, test.attachments)
+			}
+			if got := parts.BodyType(); got != test.bodyType {
+				t.Errorf("BodyType() = %q, want %q", got, test.bodyType)
+			}
+			for _, attachment := range parts.Attachments {
+				if FindAttachment(msg, attachment.Body.AttachmentId) != attachment.Body {
+					t.Errorf("attachment %s cannot be found by id %q", attachment.Filename, attachment.Body.AttachmentId)
+				}
+			}
+			body := parts.Bodies[0]
+			data, err := base64.URLEncoding.DecodeString(body.Body.Data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text, err := PartText(body, data)
+			if err != nil {
+				t.Fatalf("PartText: %v", err)
+			}
+			if !strings.Contains(text, test.text) {
+				t.Errorf("%s body does not contain %q:\n%s", body.MimeType, test.text, text)
+			}
+		})
+	}
+}
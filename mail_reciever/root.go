@@ -1,54 +1,92 @@
 package mail_reciever
 
 import (
+	"MailContactUtilty/database"
 	"MailContactUtilty/google_auth"
 	"MailContactUtilty/helper"
+	"MailContactUtilty/mail_parser"
+	"MailContactUtilty/mail_source"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/pubsub"
 	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
 type MailReciever struct {
 	*gmail.Service
-	Email        string
-	PubSubClient *pubsub.Client
-	projectId    string
+	Email           string
+	PubSubClient    *pubsub.Client
+	config          MailRecieverConfig
+	db              *database.Database
+	historyId       uint64
+	watchExpiration time.Time
+	watchMu         sync.RWMutex
+	lastSyncedAt    time.Time
+	resumed         bool
+	notifications   chan notificationRequest
+	attempts        map[string]int
+	processed       map[string]bool
 }
 
+type MailRecieverConfig struct {
+	ProjectId         string
+	Mode              string
+	PollInterval      time.Duration
+	PollJitter        time.Duration
+	TopicName         string
+	SubscriptionName  string
+	LabelIds          []string
+	LabelFilterAction string
+	AckDeadline       time.Duration
+	ExpirationPolicy  time.Duration
+	GmailEndpoint     string
+	PubSubClient      *pubsub.Client
+}
+
+const (
+	ModePubSub = "pubsub"
+	ModePush   = "push"
+	ModePoll   = "poll"
+
+	LabelFilterInclude = "include"
+	LabelFilterExclude = "exclude"
+)
+
 type PubSubMessage struct {
 	Email     string `json:"emailAddress"`
 	HistoryId uint64 `json:"historyId"`
 }
 
-func (mr *MailReciever) Reply(ctx context.Context, id string, contact *helper.Contact, originalMsg *gmail.Message, sender string) error {
-	var subject string
-	for _, header := range originalMsg.Payload.Headers {
-		if header.Name == "Subject" {
-			subject = header.Value
-		}
-	}
-	rawMessage := "From: " + mr.Email + "\r\n" +
-		"To: " + sender + "\r\n" +
-		"Subject: Re: " + subject + "\r\n" +
-		"References: " + originalMsg.Id + "\r\n" +
-		"In-Reply-To: " + originalMsg.Id + "\r\n" +
-		"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
-		"Thank you for your email. I've added the following contact information:\n" +
-		"Name: " + contact.Name + "\n" +
-		"Surname: " + contact.Surname + "\n" +
-		"Email: " + contact.Email + "\n" +
-		"Phone: " + contact.Phone + "\n" +
-		"Organization: " + contact.Organization + "\n"
+type notificationRequest struct {
+	notification PubSubMessage
+	result       chan error
+}
+
+type historyMessage struct {
+	id        string
+	historyId uint64
+}
 
+func (mr *MailReciever) Reply(ctx context.Context, id string, contacts []*helper.Contact, originalMsg *gmail.Message, sender string) error {
+	if _, err := mail_parser.ParseAddress(sender); err != nil {
+		return fmt.Errorf("invalid reply address: %v", err)
+	}
 	message := &gmail.Message{
-		Raw:      base64.URLEncoding.EncodeToString([]byte(rawMessage)),
+		Raw:      base64.URLEncoding.EncodeToString(mail_source.BuildReply(mr.Email, contacts, originalMsg, sender)),
 		ThreadId: originalMsg.ThreadId,
 	}
 
@@ -60,18 +98,97 @@ func (mr *MailReciever) Reply(ctx context.Context, id string, contact *helper.Co
 	return nil
 }
 
-func NewMailReciever(ctx context.Context, httpOption option.ClientOption, authConfig google_auth.AuthConfig, projectId string) (*MailReciever, error) {
-	srv, err := gmail.NewService(ctx, httpOption)
+func NewMailReciever(ctx context.Context, httpOption option.ClientOption, authConfig google_auth.AuthConfig, db *database.Database, config MailRecieverConfig) (*MailReciever, error) {
+	if config.Mode == "" {
+		config.Mode = ModePubSub
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	if config.PollJitter < 0 {
+		config.PollJitter = 0
+	}
+	if config.TopicName == "" {
+		config.TopicName = defaultTopicName
+	}
+	if config.SubscriptionName == "" {
+		config.SubscriptionName = defaultSubscriptionName
+	}
+	if len(config.LabelIds) == 0 {
+		config.LabelIds = []string{"INBOX"}
+	}
+	if config.LabelFilterAction == "" {
+		config.LabelFilterAction = LabelFilterInclude
+	}
+	if config.LabelFilterAction != LabelFilterInclude && config.LabelFilterAction != LabelFilterExclude {
+		return nil, fmt.Errorf("unknown label filter action: %s", config.LabelFilterAction)
+	}
+	if config.AckDeadline <= 0 {
+		config.AckDeadline = defaultAckDeadline
+	}
+	if config.ExpirationPolicy <= 0 {
+		config.ExpirationPolicy = defaultExpirationPolicy
+	}
+	gmailOptions := []option.ClientOption{httpOption}
+	if config.GmailEndpoint != "" {
+		log.Printf("Using Gmail endpoint: %s", config.GmailEndpoint)
+		gmailOptions = append(gmailOptions, option.WithEndpoint(config.GmailEndpoint))
+	}
+	srv, err := gmail.NewService(ctx, gmailOptions...)
 	if err != nil {
 		log.Printf("Unable to create people Client %v", err)
 		return nil, err
 	}
-	pubSubClient, err := pubsub.NewClient(ctx, projectId)
+	mr := &MailReciever{Service: srv, Email: authConfig.Email, config: config, db: db, notifications: make(chan notificationRequest), attempts: map[string]int{}, processed: map[string]bool{}}
+	switch config.Mode {
+	case ModePubSub, ModePush:
+		if config.PubSubClient != nil {
+			mr.PubSubClient = config.PubSubClient
+			break
+		}
+		if host := os.Getenv("PUBSUB_EMULATOR_HOST"); host != "" {
+			log.Printf("Using Pub/Sub emulator at %s", host)
+		}
+		pubSubClient, err := pubsub.NewClient(ctx, config.ProjectId)
+		if err != nil {
+			log.Printf("Unable to create pubsub client %v", err)
+			return nil, err
+		}
+		mr.PubSubClient = pubSubClient
+	case ModePoll:
+	default:
+		return nil, fmt.Errorf("unknown mail mode: %s", config.Mode)
+	}
+	return mr, nil
+}
+
+func (mr *MailReciever) LoadState(ctx context.Context) error {
+	state, err := mr.db.GetMailboxState(ctx, mr.Email)
 	if err != nil {
-		log.Printf("Unable to create pubsub client %v", err)
-		return nil, err
+		return fmt.Errorf("unable to load mailbox state: %w", err)
+	}
+	if state == nil || state.HistoryId == 0 {
+		log.Printf("No stored mailbox state for %s", mr.Email)
+		return nil
+	}
+	mr.historyId = state.HistoryId
+	mr.setWatchExpiration(state.WatchExpiration)
+	mr.lastSyncedAt = state.LastSyncedAt
+	mr.resumed = true
+	log.Printf("Resuming %s from history %d, last synced at %v", mr.Email, state.HistoryId, state.LastSyncedAt)
+	return nil
+}
+
+func (mr *MailReciever) saveState(ctx context.Context) {
+	err := mr.db.SaveMailboxState(ctx, database.MailboxState{
+		Email:           mr.Email,
+		HistoryId:       mr.historyId,
+		WatchExpiration: mr.WatchExpiration(),
+		LastSyncedAt:    mr.lastSyncedAt,
+	})
+	if err != nil {
+		log.Printf("Unable to save mailbox state: %v", err)
 	}
-	return &MailReciever{Service: srv, Email: authConfig.Email, PubSubClient: pubSubClient, projectId: projectId}, nil
 }
 
 func (mr *MailReciever) GetMessages(ctx context.Context) ([]*gmail.Message, error) {
@@ -84,7 +201,32 @@ func (mr *MailReciever) GetMessages(ctx context.Context) ([]*gmail.Message, erro
 }
 
 func (mr *MailReciever) GetUnreadMessages(ctx context.Context) ([]*gmail.Message, error) {
-	messages, err := mr.Service.Users.Messages.List("me").Q("is:unread").Context(ctx).Do()
+	if mr.config.LabelFilterAction == LabelFilterExclude {
+		return mr.listUnread(ctx)
+	}
+	var unread []*gmail.Message
+	seen := map[string]bool{}
+	for _, label := range mr.config.LabelIds {
+		messages, err := mr.listUnread(ctx, label)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range messages {
+			if !seen[msg.Id] {
+				seen[msg.Id] = true
+				unread = append(unread, msg)
+			}
+		}
+	}
+	return unread, nil
+}
+
+func (mr *MailReciever) listUnread(ctx context.Context, labelIds ...string) ([]*gmail.Message, error) {
+	call := mr.Service.Users.Messages.List("me").Q("is:unread")
+	if len(labelIds) > 0 {
+		call = call.LabelIds(labelIds...)
+	}
+	messages, err := call.Context(ctx).Do()
 	if err != nil {
 		log.Printf("Unable to retrieve messages: %v, email: %s", err, mr.Email)
 		return nil, err
@@ -93,13 +235,69 @@ func (mr *MailReciever) GetUnreadMessages(ctx context.Context) ([]*gmail.Message
 }
 
 const (
-	maxRetries = 3
-	retryDelay = 5 * time.Second
+	maxRetries          = 3
+	maxMessageAttempts  = 5
+	retryDelay          = 5 * time.Second
+	watchRenewBefore    = 24 * time.Hour
+	watchRetryInterval  = 10 * time.Minute
+	defaultPollInterval = time.Minute
+
+	defaultTopicName        = "gmail-watcher"
+	defaultSubscriptionName = "gmail-watcher-sub"
+	defaultAckDeadline      = 10 * time.Second
+	defaultExpirationPolicy = 24 * time.Hour
+	minimumRedeliveryDelay  = 10 * time.Second
+	maximumRedeliveryDelay  = 10 * time.Minute
 )
 
-func (mr *MailReciever) ListenForEmails(ctx context.Context, target chan<- *gmail.Message) error {
-	messageAlert := make(chan PubSubMessage)
+func (mr *MailReciever) ListenForEmails(ctx context.Context, target chan<- *mail_source.Delivery) error {
+	if mr.config.Mode == ModePoll {
+		return mr.pollForEmails(ctx, target)
+	}
+	return mr.listenPubSub(ctx, target)
+}
+
+func (mr *MailReciever) pollForEmails(ctx context.Context, target chan<- *mail_source.Delivery) error {
+	log.Printf("Polling for messages every %v (jitter %v)...", mr.config.PollInterval, mr.config.PollJitter)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			log.Println("Checking for new emails...")
+			if err := mr.syncHistory(ctx, target); err != nil {
+				log.Printf("Error handling messages: %v", err)
+			}
+			timer.Reset(mr.nextPoll())
+		}
+	}
+}
+
+func (mr *MailReciever) nextPoll() time.Duration {
+	if mr.config.PollJitter == 0 {
+		return mr.config.PollInterval
+	}
+	return mr.config.PollInterval + rand.N(mr.config.PollJitter)
+}
+
+func (mr *MailReciever) HandleNotification(ctx context.Context, notification PubSubMessage) error {
+	req := notificationRequest{notification: notification, result: make(chan error, 1)}
+	select {
+	case mr.notifications <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
+func (mr *MailReciever) listenPubSub(ctx context.Context, target chan<- *mail_source.Delivery) error {
 	_, err := mr.ensureTopic(ctx)
 	if err != nil {
 		return fmt.Errorf("topic setup failed: %w", err)
@@ -109,75 +307,112 @@ func (mr *MailReciever) ListenForEmails(ctx context.Context, target chan<- *gmai
 		return fmt.Errorf("watch setup failed: %w", err)
 	}
 
-	sub, err := mr.ensureSubscription(ctx)
-	if err != nil {
-		return fmt.Errorf("subscription setup failed: %w", err)
+	var sub *pubsub.Subscription
+	if mr.config.Mode == ModePubSub {
+		sub, err = mr.ensureSubscription(ctx)
+		if err != nil {
+			return fmt.Errorf("subscription setup failed: %w", err)
+		}
 	}
 
-	log.Printf("Starting to receive messages...")
-	go func() {
-		for {
-			err := sub.Receive(ctx, func(msgCtx context.Context, m *pubsub.Message) {
-				var pubSubMessage PubSubMessage
-				if err := json.Unmarshal(m.Data, &pubSubMessage); err != nil {
-					log.Printf("Unable to unmarshal message: %v", err)
-					m.Ack()
-					return
-				}
-				select {
-				case messageAlert <- pubSubMessage:
-				case <-ctx.Done():
-					return
-				}
-				m.Ack()
-			})
-			if err != nil {
-				if ctx.Err() != nil {
-					return
-				}
-				log.Printf("Receive error: %v, retrying in %v", err, retryDelay)
-				time.Sleep(retryDelay)
-				continue
-			}
+	if mr.resumed {
+		log.Printf("Catching up from history %d...", mr.historyId)
+		if err := mr.syncHistory(ctx, target); err != nil {
+			log.Printf("Error catching up: %v", err)
 		}
-	}()
+	}
+
+	if sub != nil {
+		go mr.receive(ctx, sub)
+	} else {
+		log.Printf("Waiting for push notifications...")
+	}
 
+	renewal := time.NewTimer(mr.nextWatchRenewal())
+	defer renewal.Stop()
 	for {
-		log.Println("Checking for new emails...")
+		log.Printf("Checking for new emails... (watch expires in %v)", mr.WatchTimeLeft().Round(time.Minute))
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-messageAlert:
-			if err := mr.handleNewMessages(ctx, target); err != nil {
+		case <-renewal.C:
+			log.Printf("Renewing watch, %v left", mr.WatchTimeLeft().Round(time.Minute))
+			if err := mr.setupWatch(ctx); err != nil {
+				log.Printf("Watch renewal failed: %v, retrying in %v", err, watchRetryInterval)
+				renewal.Reset(watchRetryInterval)
+				continue
+			}
+			renewal.Reset(mr.nextWatchRenewal())
+		case req := <-mr.notifications:
+			if req.notification.Email != "" && !strings.EqualFold(req.notification.Email, mr.Email) {
+				log.Printf("Ignoring notification for other mailbox: %s", req.notification.Email)
+				req.result <- nil
+				continue
+			}
+			err := mr.syncHistory(ctx, target)
+			if err != nil {
 				log.Printf("Error handling messages: %v", err)
 			}
+			req.result <- err
+		}
+	}
+}
+
+func (mr *MailReciever) receive(ctx context.Context, sub *pubsub.Subscription) {
+	log.Printf("Starting to receive messages...")
+	for {
+		err := sub.Receive(ctx, func(msgCtx context.Context, m *pubsub.Message) {
+			var pubSubMessage PubSubMessage
+			if err := json.Unmarshal(m.Data, &pubSubMessage); err != nil {
+				log.Printf("Unable to unmarshal message: %v", err)
+				m.Ack()
+				return
+			}
+			if err := mr.HandleNotification(ctx, pubSubMessage); err != nil {
+				log.Printf("Notification %s not processed, leaving for redelivery: %v", m.ID, err)
+				m.Nack()
+				return
+			}
+			m.Ack()
+		})
+		if ctx.Err() != nil {
+			return
 		}
+		log.Printf("Receive error: %v, retrying in %v", err, retryDelay)
+		time.Sleep(retryDelay)
 	}
 }
 
 func (mr *MailReciever) ensureTopic(ctx context.Context) (bool, error) {
-	exists, err := mr.PubSubClient.Topic("gmail-watcher").Exists(ctx)
+	exists, err := mr.PubSubClient.Topic(mr.config.TopicName).Exists(ctx)
 	if err != nil {
 		return false, fmt.Errorf("failed to check topic existence: %w", err)
 	}
 
 	if !exists {
-		_, err = mr.PubSubClient.CreateTopic(ctx, "gmail-watcher")
+		_, err = mr.PubSubClient.CreateTopic(ctx, mr.config.TopicName)
 		if err != nil {
 			return false, fmt.Errorf("failed to create topic: %w", err)
 		}
-		log.Printf("Created new topic: gmail-watcher")
+		log.Printf("Created new topic: %s", mr.config.TopicName)
 	}
 	return exists, nil
 }
 
 func (mr *MailReciever) setupWatch(ctx context.Context) error {
 	for i := 0; i < maxRetries; i++ {
-		_, err := mr.Service.Users.Watch("me", &gmail.WatchRequest{
-			LabelIds:  []string{"INBOX"},
-			TopicName: fmt.Sprintf("projects/%s/topics/gmail-watcher", mr.projectId),
+		resp, err := mr.Service.Users.Watch("me", &gmail.WatchRequest{
+			LabelIds:            mr.config.LabelIds,
+			LabelFilterBehavior: mr.config.LabelFilterAction,
+			TopicName:           fmt.Sprintf("projects/%s/topics/%s", mr.config.ProjectId, mr.config.TopicName),
 		}).Context(ctx).Do()
 		if err == nil {
+			if mr.historyId == 0 {
+				mr.historyId = resp.HistoryId
+			}
+			mr.setWatchExpiration(time.UnixMilli(resp.Expiration))
+			mr.saveState(ctx)
+			log.Printf("Watch active until %v (%v left)", mr.WatchExpiration(), mr.WatchTimeLeft().Round(time.Minute))
 			return nil
 		}
 		if i < maxRetries-1 {
@@ -190,60 +425,247 @@ func (mr *MailReciever) setupWatch(ctx context.Context) error {
 	return nil
 }
 
+func (mr *MailReciever) WatchExpiration() time.Time {
+	mr.watchMu.RLock()
+	defer mr.watchMu.RUnlock()
+	return mr.watchExpiration
+}
+
+func (mr *MailReciever) setWatchExpiration(expiration time.Time) {
+	mr.watchMu.Lock()
+	defer mr.watchMu.Unlock()
+	mr.watchExpiration = expiration
+}
+
+func (mr *MailReciever) WatchTimeLeft() time.Duration {
+	return time.Until(mr.WatchExpiration())
+}
+
+func (mr *MailReciever) nextWatchRenewal() time.Duration {
+	return max(mr.WatchTimeLeft()-watchRenewBefore, retryDelay)
+}
+
 func (mr *MailReciever) ensureSubscription(ctx context.Context) (*pubsub.Subscription, error) {
-	sub := mr.PubSubClient.Subscription("gmail-watcher-sub")
+	sub := mr.PubSubClient.Subscription(mr.config.SubscriptionName)
 	exists, err := sub.Exists(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check subscription existence: %w", err)
 	}
 
 	if !exists {
-		log.Printf("Creating new subscription: %s", "gmail-watcher-sub")
-		sub, err = mr.PubSubClient.CreateSubscription(ctx, "gmail-watcher-sub", pubsub.SubscriptionConfig{
-			Topic:            mr.PubSubClient.Topic("gmail-watcher"),
-			AckDeadline:      10 * time.Second,
-			ExpirationPolicy: 24 * time.Hour,
+		log.Printf("Creating new subscription: %s", mr.config.SubscriptionName)
+		sub, err = mr.PubSubClient.CreateSubscription(ctx, mr.config.SubscriptionName, pubsub.SubscriptionConfig{
+			Topic:            mr.PubSubClient.Topic(mr.config.TopicName),
+			AckDeadline:      mr.config.AckDeadline,
+			ExpirationPolicy: mr.config.ExpirationPolicy,
+			RetryPolicy:      redeliveryPolicy(),
 		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to create subscription: %w", err)
 		}
 		log.Printf("Successfully created subscription")
 	} else {
-		log.Printf("Using existing subscription: %s", "gmail-watcher-sub")
+		log.Printf("Using existing subscription: %s", mr.config.SubscriptionName)
+		config, err := sub.Config(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read subscription config: %w", err)
+		}
+		if config.RetryPolicy == nil {
+			if _, err := sub.Update(ctx, pubsub.SubscriptionConfigToUpdate{RetryPolicy: redeliveryPolicy()}); err != nil {
+				log.Printf("Unable to set a retry policy on subscription %s: %v", mr.config.SubscriptionName, err)
+			} else {
+				log.Printf("Added a retry policy to subscription %s", mr.config.SubscriptionName)
+			}
+		}
 	}
 	return sub, nil
 }
 
-func (mr *MailReciever) handleNewMessages(ctx context.Context, target chan<- *gmail.Message) error {
+func redeliveryPolicy() *pubsub.RetryPolicy {
+	return &pubsub.RetryPolicy{
+		MinimumBackoff: minimumRedeliveryDelay,
+		MaximumBackoff: maximumRedeliveryDelay,
+	}
+}
+
+func (mr *MailReciever) syncHistory(ctx context.Context, target chan<- *mail_source.Delivery) error {
+	if mr.historyId == 0 {
+		return mr.fullSync(ctx, target)
+	}
+
+	messages, latest, err := mr.listHistory(ctx, mr.historyId)
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			log.Printf("History %d is no longer available, running full sync", mr.historyId)
+			return mr.fullSync(ctx, target)
+		}
+		return fmt.Errorf("error listing history: %w", err)
+	}
+
+	var failed []historyMessage
+	var errs []error
+	for _, msg := range messages {
+		if err := mr.dispatchMessage(ctx, msg.id, target); err != nil {
+			failed = append(failed, msg)
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+	}
+	if len(failed) > 0 {
+		mr.advanceCursor(ctx, messages, failed[0].historyId)
+		return fmt.Errorf("%d messages not processed: %w", len(failed), errors.Join(errs...))
+	}
+	if latest > mr.historyId {
+		mr.historyId = latest
+	}
+	clear(mr.processed)
+	mr.lastSyncedAt = time.Now()
+	mr.saveState(ctx)
+	return nil
+}
+
+func (mr *MailReciever) advanceCursor(ctx context.Context, messages []historyMessage, failedHistoryId uint64) {
+	cursor := mr.historyId
+	for _, msg := range messages {
+		if msg.historyId < failedHistoryId && msg.historyId > cursor {
+			cursor = msg.historyId
+		}
+	}
+	if cursor > mr.historyId {
+		mr.historyId = cursor
+		mr.saveState(ctx)
+	}
+}
+
+func (mr *MailReciever) listHistory(ctx context.Context, startHistoryId uint64) ([]historyMessage, uint64, error) {
+	var messages []historyMessage
+	seen := map[string]bool{}
+	latest := startHistoryId
+	err := mr.Service.Users.History.List("me").
+		StartHistoryId(startHistoryId).
+		HistoryTypes("messageAdded").
+		Pages(ctx, func(resp *gmail.ListHistoryResponse) error {
+			for _, history := range resp.History {
+				for _, added := range history.MessagesAdded {
+					if added.Message == nil || seen[added.Message.Id] || !mr.watchesLabels(added.Message.LabelIds) {
+						continue
+					}
+					seen[added.Message.Id] = true
+					messages = append(messages, historyMessage{id: added.Message.Id, historyId: history.Id})
+				}
+			}
+			if resp.HistoryId > latest {
+				latest = resp.HistoryId
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, 0, err
+	}
+	return messages, latest, nil
+}
+
+func (mr *MailReciever) watchesLabels(labelIds []string) bool {
+	matches := slices.ContainsFunc(mr.config.LabelIds, func(label string) bool {
+		return slices.Contains(labelIds, label)
+	})
+	if mr.config.LabelFilterAction == LabelFilterExclude {
+		return !matches
+	}
+	return matches
+}
+
+func (mr *MailReciever) fullSync(ctx context.Context, target chan<- *mail_source.Delivery) error {
+	profile, err := mr.Service.Users.GetProfile("me").Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("error fetching profile: %w", err)
+	}
+	if err := mr.handleNewMessages(ctx, target); err != nil {
+		return err
+	}
+	mr.historyId = profile.HistoryId
+	clear(mr.processed)
+	mr.lastSyncedAt = time.Now()
+	mr.saveState(ctx)
+	return nil
+}
+
+func (mr *MailReciever) handleNewMessages(ctx context.Context, target chan<- *mail_source.Delivery) error {
 	newMessages, err := mr.GetUnreadMessages(ctx)
 	if err != nil {
 		return fmt.Errorf("error fetching messages: %w", err)
 	}
 
+	var errs []error
 	for _, msg := range newMessages {
-		fullMsg, err := mr.GetMessage(ctx, msg.Id)
-		if err != nil {
-			log.Printf("Error fetching message details: %v", err)
-			continue
+		if err := mr.dispatchMessage(ctx, msg.Id, target); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			errs = append(errs, err)
 		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d messages not processed: %w", len(errs), errors.Join(errs...))
+	}
+	return nil
+}
 
-		log.Printf("New email received - Subject: %s, From: %s",
-			getHeader(fullMsg.Payload.Headers, "Subject"),
-			getHeader(fullMsg.Payload.Headers, "From"))
-
-		select {
-		case target <- fullMsg:
-		case <-ctx.Done():
-			return ctx.Err()
+func (mr *MailReciever) dispatchMessage(ctx context.Context, id string, target chan<- *mail_source.Delivery) error {
+	if mr.processed[id] || mr.attempts[id] >= maxMessageAttempts {
+		return nil
+	}
+	fullMsg, err := mr.GetMessage(ctx, id)
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			log.Printf("Message %s no longer exists, skipping", id)
+			return nil
 		}
+		return mr.messageFailed(id, fmt.Errorf("error fetching message details: %w", err))
+	}
+	if !mr.watchesLabels(fullMsg.LabelIds) {
+		log.Printf("Message %s is outside the watched labels, skipping", id)
+		mr.processed[id] = true
+		return nil
+	}
+
+	log.Printf("New email received - Subject: %s, From: %s",
+		getHeader(fullMsg.Payload.Headers, "Subject"),
+		getHeader(fullMsg.Payload.Headers, "From"))
 
-		if err := mr.MarkAsRead(ctx, msg.Id); err != nil {
-			log.Printf("Failed to mark message %s as read: %v", msg.Id, err)
+	if err := mail_source.Deliver(ctx, target, fullMsg); err != nil {
+		if ctx.Err() != nil {
+			return err
 		}
+		return mr.messageFailed(id, fmt.Errorf("message %s not processed: %w", id, err))
+	}
+
+	delete(mr.attempts, id)
+	mr.processed[id] = true
+	if err := mr.MarkProcessed(ctx, id); err != nil {
+		log.Printf("Failed to mark message %s as read: %v", id, err)
 	}
 	return nil
 }
 
+func (mr *MailReciever) messageFailed(id string, err error) error {
+	if !mail_source.Retryable(err) {
+		log.Printf("Skipping message %s, it cannot be processed: %v", id, err)
+		mr.attempts[id] = maxMessageAttempts
+		return nil
+	}
+	mr.attempts[id]++
+	if mr.attempts[id] >= maxMessageAttempts {
+		log.Printf("Skipping message %s after %d attempts: %v", id, mr.attempts[id], err)
+		return nil
+	}
+	return err
+}
+
 func (mr *MailReciever) MarkAsRead(ctx context.Context, id string) error {
 	_, err := mr.Service.Users.Messages.Modify("me", id, &gmail.ModifyMessageRequest{
 		RemoveLabelIds: []string{"UNREAD"},
@@ -255,6 +677,10 @@ func (mr *MailReciever) MarkAsRead(ctx context.Context, id string) error {
 	return nil
 }
 
+func (mr *MailReciever) MarkProcessed(ctx context.Context, id string) error {
+	return mr.MarkAsRead(ctx, id)
+}
+
 func getHeader(headers []*gmail.MessagePartHeader, name string) string {
 	for _, header := range headers {
 		if header.Name == name {
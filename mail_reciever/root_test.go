@@ -0,0 +1,156 @@
+package mail_reciever
+
+import (
+	"MailContactUtilty/google_auth"
+	"MailContactUtilty/mail_source"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type fakeMailbox struct {
+	mu       sync.Mutex
+	messages []*gmail.Message
+	queries  []string
+}
+
+func (f *fakeMailbox) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/gmail/v1/users/me/")
+	var resp any
+	switch {
+	case r.Method == http.MethodGet && path == "messages":
+		labels := r.URL.Query()["labelIds"]
+		f.queries = append(f.queries, r.URL.Query().Get("q")+" "+strings.Join(labels, ","))
+		list := &gmail.ListMessagesResponse{}
+		for _, msg := range f.messages {
+			if slices.Contains(msg.LabelIds, "UNREAD") && !slices.ContainsFunc(labels, func(label string) bool { return !slices.Contains(msg.LabelIds, label) }) {
+				list.Messages = append(list.Messages, &gmail.Message{Id: msg.Id})
+			}
+		}
+		resp = list
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "messages/"):
+		for _, msg := range f.messages {
+			if path == "messages/"+msg.Id {
+				resp = msg
+			}
+		}
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/modify"):
+		resp = &gmail.Message{}
+	}
+	if resp == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestHandleNewMessagesRespectsLabels(t *testing.T) {
+	message := func(id string, labels ...string) *gmail.Message {
+		return &gmail.Message{Id: id, LabelIds: append(labels, "UNREAD"), Payload: &gmail.MessagePart{}}
+	}
+	tests := []struct {
+		name      string
+		labelIds  []string
+		action    string
+		delivered []string
+	}{
+		{name: "default inbox", delivered: []string{"inbox", "both"}},
+		{name: "include labels", labelIds: []string{"INBOX", "Label_1"}, action: LabelFilterInclude, delivered: []string{"inbox", "both", "label"}},
+		{name: "exclude labels", labelIds: []string{"SPAM", "Label_1"}, action: LabelFilterExclude, delivered: []string{"inbox", "sent"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeMailbox{messages: []*gmail.Message{
+				message("inbox", "INBOX"),
+				message("both", "INBOX", "Label_1"),
+				message("label", "Label_1"),
+				message("spam", "SPAM"),
+				message("sent", "SENT"),
+			}}
+			server := httptest.NewServer(http.HandlerFunc(fake.handle))
+			defer server.Close()
+			ctx := context.Background()
+			mr, err := NewMailReciever(ctx, option.WithHTTPClient(server.Client()), google_auth.AuthConfig{Email: "me@example.com"}, nil, MailRecieverConfig{
+				Mode:              ModePoll,
+				GmailEndpoint:     server.URL + "/",
+				LabelIds:          test.labelIds,
+				LabelFilterAction: test.action,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			target := make(chan *mail_source.Delivery)
+			var delivered []string
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for delivery := range target {
+					delivered = append(delivered, delivery.Message.Id)
+					delivery.Settle(nil)
+				}
+			}()
+			err = mr.handleNewMessages(ctx, target)
+			close(target)
+			<-done
+			if err != nil {
+				t.Fatalf("handleNewMessages: %v", err)
+			}
+			if !slices.Equal(delivered, test.delivered) {
+				t.Errorf("delivered %v, want %v (queries %q)", delivered, test.delivered, fake.queries)
+			}
+		})
+	}
+}
+
+func TestEnsureSubscriptionSetsRetryPolicy(t *testing.T) {
+	ctx := context.Background()
+	fake := pstest.NewServer()
+	defer fake.Close()
+	conn, err := grpc.NewClient(fake.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client, err := pubsub.NewClient(ctx, "test-project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	topic, err := client.CreateTopic(ctx, "gmail-watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateSubscription(ctx, "existing", pubsub.SubscriptionConfig{Topic: topic}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"created", "existing"} {
+		mr := &MailReciever{PubSubClient: client, config: MailRecieverConfig{TopicName: "gmail-watcher", SubscriptionName: name, AckDeadline: defaultAckDeadline, ExpirationPolicy: defaultExpirationPolicy}}
+		sub, err := mr.ensureSubscription(ctx)
+		if err != nil {
+			t.Fatalf("ensureSubscription(%s): %v", name, err)
+		}
+		config, err := sub.Config(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.RetryPolicy == nil || config.RetryPolicy.MinimumBackoff != minimumRedeliveryDelay || config.RetryPolicy.MaximumBackoff != maximumRedeliveryDelay {
+			t.Errorf("subscription %s has retry policy %+v", name, config.RetryPolicy)
+		}
+	}
+}
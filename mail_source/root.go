@@ -0,0 +1,203 @@
+package mail_source
+
+import (
+	"MailContactUtilty/helper"
+	"MailContactUtilty/mail_parser"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+type MailSource interface {
+	ListenForEmails(ctx context.Context, target chan<- *Delivery) error
+	GetMessage(ctx context.Context, id string) (*gmail.Message, error)
+	GetAttachment(ctx context.Context, messageId, attachmentId string) (*gmail.MessagePartBody, error)
+	MarkProcessed(ctx context.Context, id string) error
+	Reply(ctx context.Context, id string, contacts []*helper.Contact, originalMsg *gmail.Message, sender string) error
+}
+
+type Delivery struct {
+	Message *gmail.Message
+	result  chan error
+}
+
+func NewDelivery(msg *gmail.Message) *Delivery {
+	return &Delivery{Message: msg, result: make(chan error, 1)}
+}
+
+func (d *Delivery) Settle(err error) {
+	select {
+	case d.result <- err:
+	default:
+	}
+}
+
+func (d *Delivery) Wait(ctx context.Context) error {
+	select {
+	case err := <-d.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func Deliver(ctx context.Context, target chan<- *Delivery, msg *gmail.Message) error {
+	delivery := NewDelivery(msg)
+	select {
+	case target <- delivery:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return delivery.Wait(ctx)
+}
+
+type temporaryError struct {
+	err error
+}
+
+func (e *temporaryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *temporaryError) Unwrap() error {
+	return e.err
+}
+
+func Temporary(err error) error {
+	return &temporaryError{err: err}
+}
+
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var temporary *temporaryError
+	if errors.As(err, &temporary) {
+		return true
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.Code)
+	}
+	var statusErr interface{ HTTPCode() int }
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.HTTPCode())
+	}
+	return false
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= http.StatusInternalServerError
+}
+
+func BuildReply(from string, contacts []*helper.Contact, originalMsg *gmail.Message, sender string) []byte {
+	subject := getHeader(originalMsg.Payload.Headers, "Subject")
+	reference := getHeader(originalMsg.Payload.Headers, "Message-ID")
+	if reference == "" {
+		reference = originalMsg.Id
+	}
+	summary := "Thank you for your email. I've added the following contact information:\n"
+	if len(contacts) > 1 {
+		summary = fmt.Sprintf("Thank you for your email. I've added the following %d contacts:\n", len(contacts))
+	}
+	for i, contact := range contacts {
+		if i > 0 {
+			summary += "\n"
+		}
+		summary += contactDetails(contact)
+	}
+	to := sender
+	if address, err := mail_parser.ParseAddress(sender); err == nil {
+		to = address.String()
+	}
+	rawMessage := "From: " + from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: Re: " + subject + "\r\n" +
+		"References: " + reference + "\r\n" +
+		"In-Reply-To: " + reference + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
+		summary
+	return []byte(rawMessage)
+}
+
+func contactDetails(contact *helper.Contact) string {
+	details := "Name: " + contact.Name + "\n" +
+		"Surname: " + contact.Surname + "\n" +
+		"Email: " + contact.Email + "\n" +
+		"Phone: " + contact.Phone + "\n" +
+		"Organization: " + contact.Organization + "\n"
+	for _, field := range []struct{ name, value string }{
+		{"Title", contact.Title},
+		{"Department", contact.Department},
+		{"Address", contact.Address},
+		{"Website", contact.Website},
+		{"LinkedIn", contact.LinkedIn},
+	} {
+		if field.value != "" {
+			details += field.name + ": " + field.value + "\n"
+		}
+	}
+	for _, email := range contact.AllEmails() {
+		if email.Value != contact.Email {
+			details += "Email (" + typeLabel(email.Type) + "): " + email.Value + "\n"
+		}
+	}
+	for _, phone := range contact.AllPhones() {
+		if phone.Value != contact.Phone {
+			details += "Phone (" + typeLabel(phone.Type) + "): " + phone.Value + "\n"
+		}
+	}
+	return details
+}
+
+func typeLabel(valueType string) string {
+	if valueType == "" {
+		return helper.TypeOther
+	}
+	return valueType
+}
+
+type SmtpRelay struct {
+	Addr     string
+	Username string
+	Password string
+}
+
+func (r SmtpRelay) Send(from string, to []string, msg []byte) error {
+	if r.Addr == "" {
+		return fmt.Errorf("smtp address is not set")
+	}
+	host, _, err := net.SplitHostPort(r.Addr)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if r.Username != "" {
+		auth = smtp.PlainAuth("", r.Username, r.Password, host)
+	}
+	return smtp.SendMail(r.Addr, auth, from, to, msg)
+}
+
+func getHeader(headers []*gmail.MessagePartHeader, name string) string {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+	return ""
+}
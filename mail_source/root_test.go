@@ -0,0 +1,44 @@
+package mail_source
+
+import (
+	"MailContactUtilty/helper"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestBuildReply(t *testing.T) {
+	original := &gmail.Message{Id: "1", Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+		{Name: "Subject", Value: "Contacts"},
+		{Name: "Message-ID", Value: "<abc@example.com>"},
+	}}}
+	jan := &helper.Contact{Name: "Jan", Surname: "Kowalski", Email: "jan@acme.pl", Phones: []helper.TypedValue{{Value: "+48 600 100 200", Type: helper.TypeMobile}}}
+	anna := &helper.Contact{Name: "Anna", Surname: "Nowak", Title: "CFO"}
+
+	single := string(BuildReply("bot@example.com", []*helper.Contact{jan}, original, "Piotr <piotr@example.com>"))
+	for _, want := range []string{
+		"To: \"Piotr\" <piotr@example.com>\r\n",
+		"In-Reply-To: <abc@example.com>\r\n",
+		"I've added the following contact information:\nName: Jan\n",
+		"Phone (mobile): +48 600 100 200\n",
+	} {
+		if !strings.Contains(single, want) {
+			t.Errorf("single reply does not contain %q:\n%s", want, single)
+		}
+	}
+
+	summary := string(BuildReply("bot@example.com", []*helper.Contact{jan, anna}, original, "piotr@example.com"))
+	if strings.Count(summary, "Subject: ") != 1 {
+		t.Errorf("summary is not a single message:\n%s", summary)
+	}
+	for _, want := range []string{
+		"I've added the following 2 contacts:\nName: Jan\n",
+		"\n\nName: Anna\nSurname: Nowak\n",
+		"Title: CFO\n",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary does not contain %q:\n%s", want, summary)
+		}
+	}
+}
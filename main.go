@@ -1,10 +1,18 @@
 package main
 
 import (
+	"MailContactUtilty/contact_generator"
 	"MailContactUtilty/google_auth"
+	"MailContactUtilty/imap_reciever"
+	"MailContactUtilty/mail_source"
+	"MailContactUtilty/pubsub_push"
 	"MailContactUtilty/server"
+	"MailContactUtilty/smtp_reciever"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	"google.golang.org/api/gmail/v1"
@@ -16,12 +24,64 @@ func main() {
 	}
 
 	s, err := server.NewServer(server.ServerConfig{
-		DatabaseName:     os.Getenv("DATABASE_DB"),
-		DatabaseUser:     os.Getenv("DATABASE_USER"),
-		DatabasePassword: os.Getenv("DATABASE_PASSWORD"),
-		DatabaseHost:     os.Getenv("DATABASE_HOST"),
-		GeminiApiKey:     os.Getenv("GEMINI_API_KEY"),
-		ProjectId:        os.Getenv("PROJECT_ID"),
+		DatabaseName:      os.Getenv("DATABASE_DB"),
+		DatabaseUser:      os.Getenv("DATABASE_USER"),
+		DatabasePassword:  os.Getenv("DATABASE_PASSWORD"),
+		DatabaseHost:      os.Getenv("DATABASE_HOST"),
+		GeminiApiKey:      os.Getenv("GEMINI_API_KEY"),
+		ProjectId:         os.Getenv("PROJECT_ID"),
+		MailMode:          os.Getenv("MAIL_MODE"),
+		PollInterval:      durationEnv("POLL_INTERVAL"),
+		PollJitter:        durationEnv("POLL_JITTER"),
+		TopicName:         os.Getenv("PUBSUB_TOPIC"),
+		SubscriptionName:  os.Getenv("PUBSUB_SUBSCRIPTION"),
+		LabelIds:          listEnv("WATCH_LABEL_IDS"),
+		LabelFilterAction: os.Getenv("WATCH_LABEL_FILTER_ACTION"),
+		AckDeadline:       durationEnv("PUBSUB_ACK_DEADLINE"),
+		ExpirationPolicy:  durationEnv("PUBSUB_EXPIRATION"),
+		GmailEndpoint:     os.Getenv("GMAIL_ENDPOINT"),
+		MailSource:        os.Getenv("MAIL_SOURCE"),
+		ImapConfig: imap_reciever.ImapRecieverConfig{
+			Addr:     os.Getenv("IMAP_ADDR"),
+			Username: os.Getenv("IMAP_USERNAME"),
+			Password: os.Getenv("IMAP_PASSWORD"),
+			Mailbox:  os.Getenv("IMAP_MAILBOX"),
+			Insecure: os.Getenv("IMAP_INSECURE") == "true",
+			SmtpAddr: os.Getenv("SMTP_ADDR"),
+		},
+		SmtpConfig: smtp_reciever.SmtpRecieverConfig{
+			Addr:            os.Getenv("SMTP_LISTEN_ADDR"),
+			Domain:          os.Getenv("SMTP_DOMAIN"),
+			LMTP:            os.Getenv("SMTP_LMTP") == "true",
+			TrustedNetworks: listEnv("SMTP_TRUSTED_NETWORKS"),
+			Relay: mail_source.SmtpRelay{
+				Addr:     os.Getenv("SMTP_ADDR"),
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+			},
+		},
+		PushConfig: pubsub_push.VerifierConfig{
+			Audience: os.Getenv("PUSH_AUDIENCE"),
+			Issuers:  listEnv("PUSH_ISSUERS"),
+			Email:    os.Getenv("PUSH_SERVICE_ACCOUNT"),
+		},
+		PdfMaxPages: intEnv("PDF_MAX_PAGES"),
+		PdfMaxBytes: intEnv("PDF_MAX_BYTES"),
+		Extractor: contact_generator.ExtractorConfig{
+			Backend:  os.Getenv("EXTRACTOR_BACKEND"),
+			Model:    os.Getenv("EXTRACTOR_MODEL"),
+			ApiKey:   os.Getenv("EXTRACTOR_API_KEY"),
+			BaseUrl:  os.Getenv("EXTRACTOR_BASE_URL"),
+			Fallback: os.Getenv("EXTRACTOR_FALLBACK"),
+			Timeout:  durationEnv("EXTRACTOR_TIMEOUT"),
+			ImageFilter: contact_generator.ImageFilterConfig{
+				MinWidth:     intEnv("IMAGE_MIN_WIDTH"),
+				MinHeight:    intEnv("IMAGE_MIN_HEIGHT"),
+				MaxImages:    intEnv("IMAGE_MAX_COUNT"),
+				HashDistance: optionalIntEnv("IMAGE_HASH_DISTANCE"),
+				KnownHashes:  listEnv("KNOWN_IMAGE_HASHES"),
+			},
+		},
 	})
 	if err != nil {
 		log.Fatal(err)
@@ -33,3 +93,45 @@ func main() {
 	})
 	defer s.Close()
 }
+
+func durationEnv(name string) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return d
+}
+
+func intEnv(name string) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return n
+}
+
+func optionalIntEnv(name string) *int {
+	if os.Getenv(name) == "" {
+		return nil
+	}
+	n := intEnv(name)
+	return &n
+}
+
+func listEnv(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,48 @@
+package pdf_extractor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+type Document struct {
+	Pages int
+	Text  string
+}
+
+func Extract(data []byte, maxPages int) (doc *Document, err error) {
+	defer recoverPanic(&err)
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open pdf: %w", err)
+	}
+	doc = &Document{Pages: reader.NumPage()}
+	last := doc.Pages
+	if maxPages > 0 && last > maxPages {
+		last = maxPages
+	}
+	var sb strings.Builder
+	for i := 1; i <= last; i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		text, err := page.GetPlainText(nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read page %d: %w", i, err)
+		}
+		sb.WriteString(strings.TrimSpace(text))
+		sb.WriteString("\n")
+	}
+	doc.Text = strings.TrimSpace(sb.String())
+	return doc, nil
+}
+
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("malformed pdf: %v", r)
+	}
+}
@@ -0,0 +1,247 @@
+package pubsub_push
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Envelope struct {
+	Message struct {
+		Data        []byte            `json:"data"`
+		MessageId   string            `json:"messageId"`
+		PublishTime time.Time         `json:"publishTime"`
+		Attributes  map[string]string `json:"attributes"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+type VerifierConfig struct {
+	Audience string
+	Issuers  []string
+	Email    string
+	CertsUrl string
+}
+
+type Claims struct {
+	Issuer        string   `json:"iss"`
+	Audience      audience `json:"aud"`
+	Subject       string   `json:"sub"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	ExpiresAt     int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+}
+
+type Verifier struct {
+	config    VerifierConfig
+	client    *http.Client
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	checkedAt time.Time
+	static    bool
+	mu        sync.Mutex
+}
+
+const (
+	googleCertsUrl = "https://www.googleapis.com/oauth2/v3/certs"
+	keysTTL        = time.Hour
+	keysRefetchGap = time.Minute
+	clockSkew      = time.Minute
+)
+
+var defaultIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+func NewVerifier(config VerifierConfig) (*Verifier, error) {
+	if config.Audience == "" {
+		return nil, fmt.Errorf("push audience is not set")
+	}
+	if config.Email == "" {
+		return nil, fmt.Errorf("push service account email is not set")
+	}
+	if len(config.Issuers) == 0 {
+		config.Issuers = defaultIssuers
+	}
+	if config.CertsUrl == "" {
+		config.CertsUrl = googleCertsUrl
+	}
+	return &Verifier{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func NewVerifierWithKeys(config VerifierConfig, keys map[string]*rsa.PublicKey) (*Verifier, error) {
+	v, err := NewVerifier(config)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	v.static = true
+	return v, nil
+}
+
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", header.Alg)
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	now := time.Now()
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return nil, fmt.Errorf("token expired")
+	}
+	if claims.IssuedAt != 0 && now.Add(clockSkew).Before(time.Unix(claims.IssuedAt, 0)) {
+		return nil, fmt.Errorf("token issued in the future")
+	}
+	if !slices.Contains(v.config.Issuers, claims.Issuer) {
+		return nil, fmt.Errorf("unexpected issuer: %s", claims.Issuer)
+	}
+	if !slices.Contains(claims.Audience, v.config.Audience) {
+		return nil, fmt.Errorf("unexpected audience: %v", []string(claims.Audience))
+	}
+	if !strings.EqualFold(claims.Email, v.config.Email) || !claims.EmailVerified {
+		return nil, fmt.Errorf("unexpected email: %s", claims.Email)
+	}
+	return &claims, nil
+}
+
+func (v *Verifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok := v.keys[kid]
+	if ok && (v.static || time.Since(v.fetchedAt) < keysTTL) {
+		return key, nil
+	}
+	if v.static || time.Since(v.checkedAt) < keysRefetchGap {
+		if ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown key id: %s", kid)
+	}
+	v.checkedAt = time.Now()
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	key, ok = v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %s", kid)
+	}
+	return key, nil
+}
+
+func (v *Verifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.config.CertsUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch signing keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch signing keys: status %d", resp.StatusCode)
+	}
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("unable to decode signing keys: %w", err)
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+func BearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
@@ -0,0 +1,180 @@
+package pubsub_push
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testAudience = "https://example.com/pubsub/push"
+	testEmail    = "push@project.iam.gserviceaccount.com"
+	testKeyId    = "test-key"
+)
+
+func signToken(t *testing.T, key *rsa.PrivateKey, header map[string]any, claims map[string]any) string {
+	t.Helper()
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewVerifierWithKeys(VerifierConfig{Audience: testAudience, Email: testEmail}, map[string]*rsa.PublicKey{testKeyId: &key.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	validClaims := func() map[string]any {
+		return map[string]any{
+			"iss":            "https://accounts.google.com",
+			"aud":            testAudience,
+			"email":          testEmail,
+			"email_verified": true,
+			"iat":            now.Unix(),
+			"exp":            now.Add(time.Hour).Unix(),
+		}
+	}
+	validHeader := func() map[string]any {
+		return map[string]any{"alg": "RS256", "kid": testKeyId, "typ": "JWT"}
+	}
+
+	tests := []struct {
+		name    string
+		key     *rsa.PrivateKey
+		header  func(map[string]any)
+		claims  func(map[string]any)
+		token   string
+		wantErr string
+	}{
+		{name: "valid token"},
+		{name: "audience list", claims: func(c map[string]any) { c["aud"] = []string{"other", testAudience} }},
+		{name: "wrong audience", claims: func(c map[string]any) { c["aud"] = "https://attacker.example.com" }, wantErr: "unexpected audience"},
+		{name: "wrong issuer", claims: func(c map[string]any) { c["iss"] = "https://attacker.example.com" }, wantErr: "unexpected issuer"},
+		{name: "expired token", claims: func(c map[string]any) { c["exp"] = now.Add(-time.Hour).Unix() }, wantErr: "token expired"},
+		{name: "issued in the future", claims: func(c map[string]any) { c["iat"] = now.Add(time.Hour).Unix() }, wantErr: "issued in the future"},
+		{name: "hs256 algorithm", header: func(h map[string]any) { h["alg"] = "HS256" }, wantErr: "unsupported signing algorithm"},
+		{name: "none algorithm", header: func(h map[string]any) { h["alg"] = "none" }, wantErr: "unsupported signing algorithm"},
+		{name: "unknown kid", header: func(h map[string]any) { h["kid"] = "other-key" }, wantErr: "unknown key id"},
+		{name: "wrong email", claims: func(c map[string]any) { c["email"] = "someone@example.com" }, wantErr: "unexpected email"},
+		{name: "unverified email", claims: func(c map[string]any) { c["email_verified"] = false }, wantErr: "unexpected email"},
+		{name: "wrong signing key", key: otherKey, wantErr: "invalid token signature"},
+		{name: "malformed token", token: "not-a-token", wantErr: "malformed token"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token := test.token
+			if token == "" {
+				header, claims := validHeader(), validClaims()
+				if test.header != nil {
+					test.header(header)
+				}
+				if test.claims != nil {
+					test.claims(claims)
+				}
+				signingKey := key
+				if test.key != nil {
+					signingKey = test.key
+				}
+				token = signToken(t, signingKey, header, claims)
+			}
+			claims, err := verifier.Verify(context.Background(), token)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if claims.Email != testEmail {
+					t.Errorf("Email = %q, want %q", claims.Email, testEmail)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Verify error = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewVerifierRequiresEmail(t *testing.T) {
+	if _, err := NewVerifier(VerifierConfig{Audience: testAudience}); err == nil {
+		t.Error("NewVerifier accepted a config without the push service account email")
+	}
+}
+
+func TestVerifierLimitsKeyFetches(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches atomic.Int32
+	certs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": testKeyId,
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer certs.Close()
+	verifier, err := NewVerifier(VerifierConfig{Audience: testAudience, Email: testEmail, CertsUrl: certs.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	claims := map[string]any{
+		"iss":            "https://accounts.google.com",
+		"aud":            testAudience,
+		"email":          testEmail,
+		"email_verified": true,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+	valid := signToken(t, key, map[string]any{"alg": "RS256", "kid": testKeyId}, claims)
+	unknown := signToken(t, key, map[string]any{"alg": "RS256", "kid": "rotated-key"}, claims)
+
+	if _, err := verifier.Verify(context.Background(), valid); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := verifier.Verify(context.Background(), unknown); err == nil || !strings.Contains(err.Error(), "unknown key id") {
+			t.Fatalf("Verify error = %v, want unknown key id", err)
+		}
+	}
+	if _, err := verifier.Verify(context.Background(), valid); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("fetched the signing keys %d times, want 1", got)
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"MailContactUtilty/contact_generator"
+	"MailContactUtilty/google_auth"
+	"MailContactUtilty/mail_reciever"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+type fakeGmail struct {
+	*httptest.Server
+	message   *gmail.Message
+	processed chan string
+}
+
+func newFakeGmail(t *testing.T, message *gmail.Message) *fakeGmail {
+	fake := &fakeGmail{message: message, processed: make(chan string, 1)}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.handle))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *fakeGmail) handle(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/gmail/v1/users/me/")
+	var resp any
+	switch {
+	case r.Method == http.MethodPost && path == "watch":
+		resp = gmail.WatchResponse{HistoryId: 1, Expiration: time.Now().Add(7 * 24 * time.Hour).UnixMilli()}
+	case r.Method == http.MethodGet && path == "history":
+		resp = gmail.ListHistoryResponse{
+			HistoryId: 2,
+			History: []*gmail.History{{
+				Id:            2,
+				MessagesAdded: []*gmail.HistoryMessageAdded{{Message: &gmail.Message{Id: f.message.Id, LabelIds: []string{"INBOX", "UNREAD"}}}},
+			}},
+		}
+	case r.Method == http.MethodGet && path == "messages/"+f.message.Id:
+		resp = f.message
+	case r.Method == http.MethodPost && path == "messages/"+f.message.Id+"/modify":
+		select {
+		case f.processed <- f.message.Id:
+		default:
+		}
+		resp = f.message
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestPubSubNotificationReachesHandleEmail(t *testing.T) {
+	if os.Getenv("PUBSUB_EMULATOR_HOST") == "" {
+		t.Skip("PUBSUB_EMULATOR_HOST is not set")
+	}
+	if os.Getenv("DATABASE_HOST") == "" {
+		t.Skip("DATABASE_HOST is not set")
+	}
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	mailbox := "inbox-" + suffix + "@example.com"
+	topic, subscription := "gmail-watcher-"+suffix, "gmail-watcher-sub-"+suffix
+	projectId := "test-project"
+	if id := os.Getenv("PROJECT_ID"); id != "" {
+		projectId = id
+	}
+
+	fake := newFakeGmail(t, &gmail.Message{
+		Id:       "message-" + suffix,
+		ThreadId: "thread-" + suffix,
+		LabelIds: []string{"INBOX", "UNREAD"},
+		Payload: &gmail.MessagePart{
+			MimeType: "text/plain",
+			Headers: []*gmail.MessagePartHeader{
+				{Name: "From", Value: "Jan Kowalski <jan@example.com>"},
+				{Name: "Subject", Value: "Contact"},
+			},
+			Body: &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte("Hi,\n\nJan Kowalski\n+48 600 100 200"))},
+		},
+	})
+
+	s, err := NewServer(ServerConfig{
+		DatabaseName:     os.Getenv("DATABASE_DB"),
+		DatabaseUser:     os.Getenv("DATABASE_USER"),
+		DatabasePassword: os.Getenv("DATABASE_PASSWORD"),
+		DatabaseHost:     os.Getenv("DATABASE_HOST"),
+		ProjectId:        projectId,
+		MailMode:         mail_reciever.ModePubSub,
+		TopicName:        topic,
+		SubscriptionName: subscription,
+		GmailEndpoint:    fake.URL + "/",
+		Extractor:        contact_generator.ExtractorConfig{Backend: contact_generator.BackendHeuristic},
+	})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer s.Close()
+	s.WebServer = &http.Server{}
+	s.AuthClient.SetRecieverEmail(mailbox)
+	mailClient, err := mail_reciever.NewMailReciever(s.ctx, option.WithHTTPClient(fake.Client()), google_auth.AuthConfig{Email: mailbox}, s.Database, s.mailConfig)
+	if err != nil {
+		t.Fatalf("NewMailReciever: %v", err)
+	}
+	s.MailClient = mailClient
+	go s.ListenForEmails()
+	go s.Run()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	client, err := pubsub.NewClient(ctx, projectId)
+	if err != nil {
+		t.Fatalf("pubsub.NewClient: %v", err)
+	}
+	defer client.Close()
+	for {
+		exists, err := client.Subscription(subscription).Exists(ctx)
+		if err != nil {
+			t.Fatalf("checking subscription: %v", err)
+		}
+		if exists {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	data, _ := json.Marshal(mail_reciever.PubSubMessage{Email: mailbox, HistoryId: 2})
+	if _, err := client.Topic(topic).Publish(ctx, &pubsub.Message{Data: data}).Get(ctx); err != nil {
+		t.Fatalf("publishing notification: %v", err)
+	}
+
+	select {
+	case id := <-fake.processed:
+		if id != fake.message.Id {
+			t.Errorf("processed message %s, want %s", id, fake.message.Id)
+		}
+	case <-ctx.Done():
+		t.Fatal("notification did not reach HandleEmail")
+	}
+}
@@ -5,18 +5,30 @@ import (
 	"MailContactUtilty/contact_generator"
 	"MailContactUtilty/database"
 	"MailContactUtilty/google_auth"
+	"MailContactUtilty/helper"
+	"MailContactUtilty/imap_reciever"
+	"MailContactUtilty/mail_parser"
 	"MailContactUtilty/mail_reciever"
+	"MailContactUtilty/mail_source"
+	"MailContactUtilty/pdf_extractor"
+	"MailContactUtilty/pubsub_push"
+	"MailContactUtilty/smtp_reciever"
+	"MailContactUtilty/vcard_parser"
 	"MailContactUtilty/web_handler"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
-	"regexp"
+	netmail "net/mail"
+	"net/url"
 	"slices"
+	"strings"
 	"time"
 
+	"cloud.google.com/go/pubsub"
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/api/option"
 	"google.golang.org/api/people/v1"
@@ -24,27 +36,71 @@ import (
 
 type Server struct {
 	AuthClient      *google_auth.Auth
-	MailClient      *mail_reciever.MailReciever
-	ContactClient   *contact_generator.ContactGenerator
+	Database        *database.Database
+	MailClient      mail_source.MailSource
+	ContactClient   contact_generator.Extractor
 	WebServer       *http.Server
 	ctx             context.Context
 	cancel          context.CancelFunc
 	errChan         chan error
-	mailList        chan *gmail.Message
-	projectId       string
+	mailList        chan *mail_source.Delivery
+	mailSource      string
+	mailConfig      mail_reciever.MailRecieverConfig
+	imapConfig      imap_reciever.ImapRecieverConfig
+	smtpConfig      smtp_reciever.SmtpRecieverConfig
+	pushConfig      pubsub_push.VerifierConfig
+	pdfMaxPages     int
+	pdfMaxBytes     int
+	mux             *http.ServeMux
 	credentailsPath string
+	pendingReplies  map[string]*pendingReply
+}
+
+type pendingReply struct {
+	contacts []*helper.Contact
+	savedAt  time.Time
 }
 
 type ServerConfig struct {
-	DatabaseName     string
-	DatabaseUser     string
-	DatabasePassword string
-	DatabaseHost     string
-	GeminiApiKey     string
-	ProjectId        string
-	RecieverEmail    string
+	DatabaseName      string
+	DatabaseUser      string
+	DatabasePassword  string
+	DatabaseHost      string
+	GeminiApiKey      string
+	ProjectId         string
+	RecieverEmail     string
+	MailMode          string
+	PollInterval      time.Duration
+	PollJitter        time.Duration
+	TopicName         string
+	SubscriptionName  string
+	LabelIds          []string
+	LabelFilterAction string
+	AckDeadline       time.Duration
+	ExpirationPolicy  time.Duration
+	GmailEndpoint     string
+	PubSubClient      *pubsub.Client
+	MailSource        string
+	ImapConfig        imap_reciever.ImapRecieverConfig
+	SmtpConfig        smtp_reciever.SmtpRecieverConfig
+	PushConfig        pubsub_push.VerifierConfig
+	PdfMaxPages       int
+	PdfMaxBytes       int
+	Extractor         contact_generator.ExtractorConfig
 }
 
+const (
+	MailSourceGmail = "gmail"
+	MailSourceImap  = "imap"
+	MailSourceSmtp  = "smtp"
+)
+
+const (
+	defaultPdfMaxPages = 10
+	defaultPdfMaxBytes = 5 << 20
+	pendingReplyTTL    = 24 * time.Hour
+)
+
 func NewServer(config ServerConfig) (*Server, error) {
 	dbConfig := database.DatabaseConfig{
 		Host:     config.DatabaseHost,
@@ -53,46 +109,74 @@ func NewServer(config ServerConfig) (*Server, error) {
 		Database: config.DatabaseName,
 	}
 	ctx, cancel := context.WithCancel(context.Background())
-	auth, err := google_auth.NewAuth(ctx, dbConfig)
+	db, err := database.NewDatabase(ctx, dbConfig)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	auth, err := google_auth.NewAuth(ctx, db)
 	if err != nil {
 		cancel()
 		return nil, err
 	}
-	contactClient, err := contact_generator.NewContactGenerator(ctx, config.GeminiApiKey)
+	if config.PdfMaxPages <= 0 {
+		config.PdfMaxPages = defaultPdfMaxPages
+	}
+	if config.PdfMaxBytes <= 0 {
+		config.PdfMaxBytes = defaultPdfMaxBytes
+	}
+	if config.Extractor.ApiKey == "" && (config.Extractor.Backend == "" || config.Extractor.Backend == contact_generator.BackendGemini) {
+		config.Extractor.ApiKey = config.GeminiApiKey
+	}
+	contactClient, err := contact_generator.NewExtractor(ctx, config.Extractor)
 	if err != nil {
 		cancel()
 		return nil, err
 	}
 	return &Server{
-		AuthClient:    auth,
-		errChan:       make(chan error, 1),
-		ctx:           ctx,
-		cancel:        cancel,
-		mailList:      make(chan *gmail.Message),
-		ContactClient: contactClient,
-		projectId:     config.ProjectId,
+		AuthClient:     auth,
+		Database:       db,
+		errChan:        make(chan error, 1),
+		ctx:            ctx,
+		cancel:         cancel,
+		mailList:       make(chan *mail_source.Delivery),
+		pendingReplies: map[string]*pendingReply{},
+		ContactClient:  contactClient,
+		mailSource:     config.MailSource,
+		imapConfig:     config.ImapConfig,
+		smtpConfig:     config.SmtpConfig,
+		pushConfig:     config.PushConfig,
+		pdfMaxPages:    config.PdfMaxPages,
+		pdfMaxBytes:    config.PdfMaxBytes,
+		mailConfig: mail_reciever.MailRecieverConfig{
+			ProjectId:         config.ProjectId,
+			Mode:              config.MailMode,
+			PollInterval:      config.PollInterval,
+			PollJitter:        config.PollJitter,
+			TopicName:         config.TopicName,
+			SubscriptionName:  config.SubscriptionName,
+			LabelIds:          config.LabelIds,
+			LabelFilterAction: config.LabelFilterAction,
+			AckDeadline:       config.AckDeadline,
+			ExpirationPolicy:  config.ExpirationPolicy,
+			GmailEndpoint:     config.GmailEndpoint,
+			PubSubClient:      config.PubSubClient,
+		},
 	}, nil
 }
 func (s *Server) Start(authConfig *google_auth.AuthConfig) {
 	s.credentailsPath = authConfig.Path
-	sm := http.NewServeMux()
-	sm.Handle("/register", web_handler.Register(s.AuthClient, s.credentailsPath))
-	sm.Handle("/auth", web_handler.Auth(s.AuthClient, s.credentailsPath))
+	s.mux = http.NewServeMux()
+	s.mux.Handle("/register", web_handler.Register(s.AuthClient, s.credentailsPath))
+	s.mux.Handle("/auth", web_handler.Auth(s.AuthClient, s.credentailsPath))
 	s.WebServer = &http.Server{
 		Addr:        ":8080",
-		Handler:     sm,
+		Handler:     s.mux,
 		BaseContext: func(_ net.Listener) context.Context { return s.ctx },
 	}
 	log.Println("Starting server...")
 	go s.ServeWeb()
-	s.AuthClient.StartAuth(s.ctx, authConfig)
-	client, err := s.AuthClient.GetHTTPClient(s.ctx, authConfig)
-	if err != nil {
-		log.Printf("Unable to create http client: %v", err)
-		s.cancel()
-		return
-	}
-	mailClient, err := mail_reciever.NewMailReciever(s.ctx, option.WithHTTPClient(client), *authConfig, s.projectId)
+	mailClient, err := s.newMailSource(authConfig)
 	if err != nil {
 		log.Printf("Unable to create mail client: %v", err)
 		s.cancel()
@@ -105,6 +189,40 @@ func (s *Server) Start(authConfig *google_auth.AuthConfig) {
 	log.Println("Starting main loop...")
 	s.Run()
 }
+func (s *Server) newMailSource(authConfig *google_auth.AuthConfig) (mail_source.MailSource, error) {
+	switch s.mailSource {
+	case MailSourceImap:
+		s.AuthClient.SetRecieverEmail(authConfig.Email)
+		return imap_reciever.NewImapReciever(authConfig.Email, s.imapConfig)
+	case MailSourceSmtp:
+		s.AuthClient.SetRecieverEmail(authConfig.Email)
+		return smtp_reciever.NewSmtpReciever(authConfig.Email, s.smtpConfig)
+	case MailSourceGmail, "":
+		s.AuthClient.StartAuth(s.ctx, authConfig)
+		client, err := s.AuthClient.GetHTTPClient(s.ctx, authConfig)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create http client: %w", err)
+		}
+		mailClient, err := mail_reciever.NewMailReciever(s.ctx, option.WithHTTPClient(client), *authConfig, s.Database, s.mailConfig)
+		if err != nil {
+			return nil, err
+		}
+		if err := mailClient.LoadState(s.ctx); err != nil {
+			return nil, err
+		}
+		if s.mailConfig.Mode == mail_reciever.ModePush {
+			verifier, err := pubsub_push.NewVerifier(s.pushConfig)
+			if err != nil {
+				return nil, err
+			}
+			s.mux.Handle("/pubsub/push", web_handler.PubSubPush(verifier, mailClient.HandleNotification))
+		}
+		return mailClient, nil
+	default:
+		return nil, fmt.Errorf("unknown mail source: %s", s.mailSource)
+	}
+}
+
 func (s *Server) Close() {
 	s.ContactClient.Close()
 	s.cancel()
@@ -121,90 +239,398 @@ func (s *Server) ListenForEmails() {
 		s.errChan <- err
 	}
 }
-func (s *Server) HandleEmail(mail *gmail.Message) {
-	var sender string
-	for _, header := range mail.Payload.Headers {
-		if header.Name == "From" {
-			emailRegexp := regexp.MustCompile(`<([^>]+)>`)
-			matches := emailRegexp.FindStringSubmatch(header.Value)
-			if len(matches) > 1 {
-				sender = matches[1]
-			}
-			break
-		}
-	}
-	log.Println("Processing email from: ", sender)
+func (s *Server) HandleEmail(mail *gmail.Message) error {
+	addresses := mail_parser.GetAddresses(mail.Payload.Headers)
+	log.Println("Processing email from: ", addresses.From)
 	emails, err := s.AuthClient.GetEmails(s.ctx)
 	if err != nil {
-		log.Printf("Error getting emails: %v", err)
-		return
+		return fmt.Errorf("error getting emails: %w", err)
 	}
-	if !slices.Contains(emails, sender) {
-		log.Printf("Email not from sender: %s, from: %s", emails, sender)
-		return
+	sender := allowedSender(emails, addresses)
+	if sender == "" {
+		log.Printf("Email not from sender: %s, from: %s", emails, addresses.From)
+		return nil
 	}
+	replyTo := replyAddress(emails, addresses, sender)
 	authConfig := google_auth.AuthConfig{Email: sender, Scopes: []string{people.ContactsScope}, Path: s.credentailsPath}
 	client, err := s.AuthClient.GetHTTPClient(s.ctx, &authConfig)
 	if err != nil {
-		log.Printf("Unable to create http client: %v", err)
-		return
+		return fmt.Errorf("unable to create http client: %w", err)
 	}
 	user_auth := option.WithHTTPClient(client)
 	client_ca, err := contact_adder.NewContactAdder(s.ctx, user_auth)
 	if err != nil {
-		log.Printf("Unable to create contact client: %v", err)
-		return
+		return fmt.Errorf("unable to create contact client: %w", err)
 	}
 
 	mailContent, err := s.MailClient.GetMessage(s.ctx, mail.Id)
 	if err != nil {
-		log.Printf("Error getting message: %v", err)
-		return
+		return fmt.Errorf("error getting message: %w", err)
+	}
+	if _, ok := s.pendingReplies[replyKey(mailContent)]; ok {
+		log.Printf("Contacts from message %s are already saved, retrying the reply", mail.Id)
+		return s.sendReply(mailContent, replyTo)
+	}
+	parts := mail_parser.Walk(mailContent.Payload)
+	if contacts := s.vcardContacts(mail.Id, parts); len(contacts) > 0 {
+		log.Printf("Importing %d contacts from vCard attachments", len(contacts))
+		return s.saveContacts(client_ca, mailContent, replyTo, contacts)
 	}
 	fullMailText := ""
-	images := []contact_generator.ImageData{}
-	for _, part := range mailContent.Payload.Parts {
-		if part.MimeType == "text/plain" {
-			mailString, err := base64.URLEncoding.DecodeString(part.Body.Data)
-			if err != nil {
-				log.Printf("Error decoding message: %v", err)
-				continue
+	var links []mail_parser.Link
+	bodyType := parts.BodyType()
+	for _, part := range parts.Bodies {
+		if part.MimeType != bodyType {
+			continue
+		}
+		mailString, err := s.partText(mail.Id, part)
+		if err != nil {
+			log.Printf("Error decoding message: %v", err)
+			continue
+		}
+		if bodyType == "text/html" {
+			text, partLinks := mail_parser.HtmlToText(mailString)
+			fullMailText += text + "\n"
+			links = append(links, partLinks...)
+			continue
+		}
+		fullMailText += mailString
+	}
+	note := fullMailText
+	original := s.forwardedMessage(mail.Id, parts)
+	if original == nil {
+		original = mail_parser.ParseForwarded(fullMailText)
+		if original != nil {
+			note = original.Note
+		}
+	}
+	body := mail_parser.CleanBody(note)
+	mailText, signature := body.Text, body.Signature
+	if original != nil {
+		log.Printf("Forwarded message from: %s <%s>", original.Name, original.Email)
+		if original.Body != "" {
+			forwarded := mail_parser.CleanBody(original.Body)
+			mailText = "Message from the forwarder:\n" + body.Text + "\n\nForwarded message:\n" + forwarded.Text
+			signature = forwarded.Signature
+		}
+	}
+	mailText += linksText(mailText, links)
+	contact, err := s.ContactClient.Generate(s.ctx, contact_generator.MailData{
+		Text:      mailText,
+		Signature: signature,
+		Images:    s.messageImages(mail.Id, parts),
+		Documents: s.pdfDocuments(mail.Id, parts),
+		Forwarder: sender,
+		Original:  original,
+	})
+	if err != nil {
+		return fmt.Errorf("error generating contact: %w", err)
+	}
+	return s.saveContacts(client_ca, mailContent, replyTo, []*helper.Contact{contact})
+}
+
+func allowedSender(emails []string, addresses mail_parser.MessageAddresses) string {
+	for _, address := range []*netmail.Address{addresses.From, addresses.Sender} {
+		if address == nil {
+			continue
+		}
+		for _, email := range emails {
+			if mail_parser.SameAddress(email, address.Address) {
+				return email
 			}
-			fullMailText += string(mailString)
 		}
-		if part.MimeType == "image/jpeg" || part.MimeType == "image/png" || part.MimeType == "image/svg" {
-			body, err := s.MailClient.GetAttachment(s.ctx, mail.Id, part.Body.AttachmentId)
-			if err != nil {
-				log.Printf("Error getting attachment: %v", err)
-				continue
+	}
+	return ""
+}
+
+func replyAddress(emails []string, addresses mail_parser.MessageAddresses, sender string) string {
+	for _, address := range addresses.ReplyTo {
+		if slices.ContainsFunc(emails, func(email string) bool { return mail_parser.SameAddress(email, address.Address) }) {
+			return address.String()
+		}
+	}
+	for _, address := range []*netmail.Address{addresses.From, addresses.Sender} {
+		if address != nil && mail_parser.SameAddress(address.Address, sender) {
+			return address.String()
+		}
+	}
+	return sender
+}
+
+func (s *Server) saveContacts(client_ca *contact_adder.ContactAdder, mailContent *gmail.Message, replyTo string, contacts []*helper.Contact) error {
+	added := make([]*helper.Contact, 0, len(contacts))
+	var errs []error
+	for _, contact := range contacts {
+		if _, err := client_ca.AddContact(s.ctx, contact); err != nil {
+			log.Printf("Error adding contact %s %s: %v", contact.Name, contact.Surname, err)
+			errs = append(errs, err)
+			continue
+		}
+		added = append(added, contact)
+	}
+	if len(added) == 0 {
+		return fmt.Errorf("error adding contact: %w", errors.Join(errs...))
+	}
+	if len(errs) > 0 {
+		log.Printf("Added %d of %d contacts from message %s", len(added), len(contacts), mailContent.Id)
+	}
+	if s.pendingReplies == nil {
+		s.pendingReplies = map[string]*pendingReply{}
+	}
+	for key, pending := range s.pendingReplies {
+		if time.Since(pending.savedAt) > pendingReplyTTL {
+			delete(s.pendingReplies, key)
+		}
+	}
+	s.pendingReplies[replyKey(mailContent)] = &pendingReply{contacts: added, savedAt: time.Now()}
+	return s.sendReply(mailContent, replyTo)
+}
+
+func (s *Server) sendReply(mailContent *gmail.Message, replyTo string) error {
+	key := replyKey(mailContent)
+	if err := s.MailClient.Reply(s.ctx, mailContent.Id, s.pendingReplies[key].contacts, mailContent, replyTo); err != nil {
+		return mail_source.Temporary(fmt.Errorf("error replying to message: %w", err))
+	}
+	delete(s.pendingReplies, key)
+	return nil
+}
+
+func replyKey(mailContent *gmail.Message) string {
+	if messageId := mail_parser.GetHeader(mailContent.Payload.Headers, "Message-ID"); messageId != "" {
+		return messageId
+	}
+	return mailContent.Id
+}
+
+func (s *Server) vcardContacts(messageId string, parts mail_parser.Parts) []*helper.Contact {
+	var contacts []*helper.Contact
+	for _, part := range slices.Concat(parts.Bodies, parts.Attachments) {
+		if !isVcard(part) {
+			continue
+		}
+		text, err := s.partText(messageId, part)
+		if err != nil {
+			log.Printf("Error decoding vCard: %v", err)
+			continue
+		}
+		cards, err := vcard_parser.Parse([]byte(text))
+		if err != nil {
+			log.Printf("Error parsing vCard %s: %v", part.Filename, err)
+			continue
+		}
+		contacts = append(contacts, cards...)
+	}
+	return contacts
+}
+
+func isVcard(part *gmail.MessagePart) bool {
+	switch strings.ToLower(part.MimeType) {
+	case "text/vcard", "text/x-vcard", "text/directory":
+		return true
+	}
+	return strings.HasSuffix(strings.ToLower(part.Filename), ".vcf")
+}
+
+func (s *Server) forwardedMessage(messageId string, parts mail_parser.Parts) *mail_parser.ForwardedMessage {
+	for _, part := range parts.Attachments {
+		if !strings.EqualFold(part.MimeType, "message/rfc822") {
+			continue
+		}
+		body, err := s.partBody(messageId, part)
+		if err != nil {
+			log.Printf("Error getting attachment: %v", err)
+			continue
+		}
+		raw, err := base64.URLEncoding.DecodeString(body.Data)
+		if err != nil {
+			log.Printf("Error decoding attachment: %v", err)
+			continue
+		}
+		original, err := mail_parser.ParseForwardedAttachment(raw)
+		if err != nil {
+			log.Printf("Error parsing attached message: %v", err)
+			continue
+		}
+		if original.Email != "" {
+			return original
+		}
+	}
+	return nil
+}
+
+type imagePlacement struct {
+	position string
+	offset   int
+}
+
+type placedImage struct {
+	image  contact_generator.ImageData
+	offset int
+}
+
+func (s *Server) messageImages(messageId string, parts mail_parser.Parts) []contact_generator.ImageData {
+	placements := s.inlineImagePlacements(messageId, parts)
+	var signature []placedImage
+	var attached []contact_generator.ImageData
+	for _, part := range parts.Attachments {
+		if !strings.HasPrefix(strings.ToLower(part.MimeType), "image/") {
+			continue
+		}
+		contentId := strings.Trim(mail_parser.GetHeader(part.Headers, "Content-ID"), "<> ")
+		placement, referenced := placements[contentId]
+		if !referenced {
+			placement.position = contact_generator.ImagePositionAttached
+		}
+		if placement.position == contact_generator.ImagePositionBody {
+			log.Printf("Skipping inline image %s: not part of the signature", contentId)
+			continue
+		}
+		body, err := s.partBody(messageId, part)
+		if err != nil {
+			log.Printf("Error getting attachment: %v", err)
+			continue
+		}
+		data, err := base64.URLEncoding.DecodeString(body.Data)
+		if err != nil {
+			log.Printf("Error decoding attachment: %v", err)
+			continue
+		}
+		image := contact_generator.ImageData{
+			Type:     strings.ToLower(part.MimeType),
+			Data:     data,
+			Position: placement.position,
+		}
+		if placement.position == contact_generator.ImagePositionSignature {
+			signature = append(signature, placedImage{image: image, offset: placement.offset})
+			continue
+		}
+		attached = append(attached, image)
+	}
+	slices.SortStableFunc(signature, func(a, b placedImage) int {
+		return a.offset - b.offset
+	})
+	images := make([]contact_generator.ImageData, 0, len(signature)+len(attached))
+	for _, placed := range signature {
+		images = append(images, placed.image)
+	}
+	return append(images, attached...)
+}
+
+func (s *Server) inlineImagePlacements(messageId string, parts mail_parser.Parts) map[string]imagePlacement {
+	placements := map[string]imagePlacement{}
+	for _, part := range parts.Bodies {
+		if part.MimeType != "text/html" {
+			continue
+		}
+		src, err := s.partText(messageId, part)
+		if err != nil {
+			log.Printf("Error decoding message: %v", err)
+			continue
+		}
+		text, _, images := mail_parser.HtmlToTextWithImages(src)
+		for _, image := range images {
+			if _, ok := placements[imageContentId(image)]; !ok {
+				placements[imageContentId(image)] = imagePlacement{position: contact_generator.ImagePositionBody, offset: image.Offset}
+			}
+		}
+		body, kept := mail_parser.CleanBodyWithImages(text, images)
+		signatureStart := mail_parser.SignatureStart(body.Text)
+		for _, image := range kept {
+			contentId := imageContentId(image)
+			if image.Offset >= signatureStart && placements[contentId].position != contact_generator.ImagePositionSignature {
+				placements[contentId] = imagePlacement{position: contact_generator.ImagePositionSignature, offset: image.Offset}
 			}
-			images = append(images, contact_generator.ImageData{
-				Type: part.MimeType,
-				Data: []byte(body.Data),
-			})
 		}
 	}
-	contact, err := s.ContactClient.Generate(s.ctx, fullMailText, images)
+	return placements
+}
+
+func imageContentId(image mail_parser.InlineImage) string {
+	contentId, err := url.PathUnescape(image.ContentId)
 	if err != nil {
-		log.Printf("Error generating contact: %v", err)
-		return
+		return image.ContentId
+	}
+	return contentId
+}
+
+func (s *Server) pdfDocuments(messageId string, parts mail_parser.Parts) []contact_generator.DocumentData {
+	var documents []contact_generator.DocumentData
+	for _, part := range parts.Attachments {
+		if !strings.EqualFold(part.MimeType, "application/pdf") && !strings.HasSuffix(strings.ToLower(part.Filename), ".pdf") {
+			continue
+		}
+		body, err := s.partBody(messageId, part)
+		if err != nil {
+			log.Printf("Error getting attachment: %v", err)
+			continue
+		}
+		data, err := base64.URLEncoding.DecodeString(body.Data)
+		if err != nil {
+			log.Printf("Error decoding attachment: %v", err)
+			continue
+		}
+		doc, err := pdf_extractor.Extract(data, s.pdfMaxPages)
+		if err != nil {
+			log.Printf("Unable to read PDF %s, sending it without a text layer: %v", part.Filename, err)
+			doc = &pdf_extractor.Document{}
+		}
+		document := contact_generator.DocumentData{Type: "application/pdf", Text: doc.Text}
+		if len(data) <= s.pdfMaxBytes && doc.Pages <= s.pdfMaxPages {
+			document.Data = data
+		} else {
+			log.Printf("PDF %s exceeds limits (%d bytes, %d pages), using its text layer", part.Filename, len(data), doc.Pages)
+		}
+		if len(document.Data) == 0 && document.Text == "" {
+			continue
+		}
+		documents = append(documents, document)
+	}
+	return documents
+}
+
+func linksText(text string, links []mail_parser.Link) string {
+	var kept []string
+	for _, link := range links {
+		if (link.Text != "" && strings.Contains(text, link.Text)) || strings.Contains(text, link.Href) {
+			if !slices.Contains(kept, link.Href) {
+				kept = append(kept, link.Href)
+			}
+		}
+	}
+	if len(kept) == 0 {
+		return ""
 	}
-	_, err = client_ca.AddContact(s.ctx, contact)
+	return "\n\nLinks:\n- " + strings.Join(kept, "\n- ") + "\n"
+}
+
+func (s *Server) partText(messageId string, part *gmail.MessagePart) (string, error) {
+	body, err := s.partBody(messageId, part)
 	if err != nil {
-		log.Printf("Error adding contact: %v", err)
-		return
+		return "", err
 	}
-	err = s.MailClient.Reply(s.ctx, mailContent.Id, contact, mailContent, sender)
+	data, err := base64.URLEncoding.DecodeString(body.Data)
 	if err != nil {
-		log.Printf("Error replying to message: %v", err)
+		return "", err
+	}
+	return mail_parser.PartText(part, data)
+}
+
+func (s *Server) partBody(messageId string, part *gmail.MessagePart) (*gmail.MessagePartBody, error) {
+	if part.Body.Data != "" {
+		return part.Body, nil
 	}
+	return s.MailClient.GetAttachment(s.ctx, messageId, part.Body.AttachmentId)
 }
 
 func (s *Server) Run() {
 	for {
 		select {
-		case mail := <-s.mailList:
-			s.HandleEmail(mail)
+		case delivery := <-s.mailList:
+			err := s.HandleEmail(delivery.Message)
+			if err != nil {
+				log.Printf("Error handling email %s: %v", delivery.Message.Id, err)
+			}
+			delivery.Settle(err)
 		case err := <-s.errChan:
 			log.Printf("Server error: %v\n", err)
 			s.cancel()
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"MailContactUtilty/contact_adder"
+	"MailContactUtilty/contact_generator"
+	"MailContactUtilty/helper"
+	"MailContactUtilty/mail_parser"
+	"MailContactUtilty/mail_source"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	netmail "net/mail"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+	peopleapi "google.golang.org/api/people/v1"
+)
+
+func TestInlineImagePlacementsUsesLatestSignature(t *testing.T) {
+	html := `<p>Please add me.</p>
+<p>Best regards,<br>Anna Nowak<br>Sales Manager<br>+48 600 100 200<br>anna@acme.pl<br><img src="cid:anna-logo"></p>
+<p><img src="cid:banner"></p>
+<div>On Mon, 1 Jan 2024 at 10:00, Jan &lt;jan@example.com&gt; wrote:</div>
+<blockquote><p>Who are you?</p><p>Jan Kowalski<br>CEO<br>+48 700 200 300<br>jan@example.com<br><img src="cid:jan-logo"></p></blockquote>`
+	parts := mail_parser.Parts{Bodies: []*gmail.MessagePart{{
+		MimeType: "text/html",
+		Headers:  []*gmail.MessagePartHeader{{Name: "Content-Type", Value: "text/html; charset=utf-8"}},
+		Body:     &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte(html))},
+	}}}
+	placements := (&Server{}).inlineImagePlacements("1", parts)
+	for contentId, want := range map[string]string{
+		"anna-logo": contact_generator.ImagePositionSignature,
+		"banner":    contact_generator.ImagePositionSignature,
+		"jan-logo":  contact_generator.ImagePositionBody,
+	} {
+		if got := placements[contentId].position; got != want {
+			t.Errorf("%s placed in %q, want %q", contentId, got, want)
+		}
+	}
+}
+
+func TestPdfDocumentsKeepsUnreadablePdf(t *testing.T) {
+	data := []byte("%PDF-1.7\nnot a pdf the parser understands")
+	parts := mail_parser.Parts{Attachments: []*gmail.MessagePart{{
+		MimeType: "application/pdf",
+		Filename: "card.pdf",
+		Body:     &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString(data)},
+	}}}
+	s := &Server{pdfMaxPages: defaultPdfMaxPages, pdfMaxBytes: defaultPdfMaxBytes}
+	documents := s.pdfDocuments("1", parts)
+	if len(documents) != 1 || string(documents[0].Data) != string(data) || documents[0].Text != "" {
+		t.Fatalf("documents = %+v, want the raw PDF without text", documents)
+	}
+	s.pdfMaxBytes = 8
+	if documents := s.pdfDocuments("1", parts); len(documents) != 0 {
+		t.Errorf("oversized unreadable PDF was kept: %+v", documents)
+	}
+}
+
+type stubMailSource struct {
+	replyErrs []error
+	replies   [][]*helper.Contact
+}
+
+func (m *stubMailSource) ListenForEmails(ctx context.Context, target chan<- *mail_source.Delivery) error {
+	return nil
+}
+
+func (m *stubMailSource) GetMessage(ctx context.Context, id string) (*gmail.Message, error) {
+	return nil, fmt.Errorf("message %s not found", id)
+}
+
+func (m *stubMailSource) GetAttachment(ctx context.Context, messageId, attachmentId string) (*gmail.MessagePartBody, error) {
+	return nil, fmt.Errorf("attachment %s not found", attachmentId)
+}
+
+func (m *stubMailSource) MarkProcessed(ctx context.Context, id string) error {
+	return nil
+}
+
+func (m *stubMailSource) Reply(ctx context.Context, id string, contacts []*helper.Contact, originalMsg *gmail.Message, sender string) error {
+	m.replies = append(m.replies, contacts)
+	if len(m.replyErrs) > 0 {
+		err := m.replyErrs[0]
+		m.replyErrs = m.replyErrs[1:]
+		return err
+	}
+	return nil
+}
+
+func TestSaveContactsRetriesOnlyTheReply(t *testing.T) {
+	var created atomic.Int32
+	people := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, ":searchContacts"):
+			fmt.Fprint(w, `{}`)
+		case strings.HasSuffix(r.URL.Path, ":createContact"):
+			created.Add(1)
+			fmt.Fprint(w, `{"resourceName":"people/c1"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer people.Close()
+	ctx := context.Background()
+	service, err := peopleapi.NewService(ctx, option.WithHTTPClient(people.Client()), option.WithEndpoint(people.URL+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mailSource := &stubMailSource{replyErrs: []error{fmt.Errorf("relay unavailable")}}
+	s := &Server{ctx: ctx, MailClient: mailSource}
+	mailContent := &gmail.Message{Id: "1", Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{{Name: "Message-ID", Value: "<cards@example.com>"}}}}
+	contacts := []*helper.Contact{{Name: "Jan", Surname: "Kowalski"}, {Name: "Anna", Surname: "Nowak"}}
+
+	err = s.saveContacts(&contact_adder.ContactAdder{Service: service}, mailContent, "jan@example.com", contacts)
+	if err == nil || !mail_source.Retryable(err) {
+		t.Fatalf("saveContacts error = %v, want a retryable error", err)
+	}
+	if _, ok := s.pendingReplies[replyKey(mailContent)]; !ok {
+		t.Fatal("saved contacts are not kept for the retry")
+	}
+	if err := s.sendReply(mailContent, "jan@example.com"); err != nil {
+		t.Fatalf("sendReply: %v", err)
+	}
+	if got := created.Load(); got != 2 {
+		t.Errorf("created %d contacts, want 2", got)
+	}
+	if len(mailSource.replies) != 2 || len(mailSource.replies[1]) != 2 {
+		t.Errorf("replies = %v, want the retried reply to list both contacts", mailSource.replies)
+	}
+	if len(s.pendingReplies) != 0 {
+		t.Errorf("pending replies left after sending: %v", s.pendingReplies)
+	}
+}
+
+func TestReplyAddress(t *testing.T) {
+	emails := []string{"jan@example.com", "assistant@example.com"}
+	jan := &netmail.Address{Name: "Jan Kowalski", Address: "Jan@Example.com"}
+	tests := []struct {
+		name      string
+		addresses mail_parser.MessageAddresses
+		want      string
+	}{
+		{name: "from", addresses: mail_parser.MessageAddresses{From: jan}, want: jan.String()},
+		{name: "reply-to outside the allow-list", addresses: mail_parser.MessageAddresses{From: jan, ReplyTo: []*netmail.Address{{Address: "attacker@example.net"}}}, want: jan.String()},
+		{name: "allow-listed reply-to", addresses: mail_parser.MessageAddresses{From: jan, ReplyTo: []*netmail.Address{{Address: "attacker@example.net"}, {Address: "assistant@example.com"}}}, want: "<assistant@example.com>"},
+		{name: "sender header", addresses: mail_parser.MessageAddresses{From: &netmail.Address{Address: "list@example.org"}, Sender: jan}, want: jan.String()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sender := allowedSender(emails, test.addresses)
+			if got := replyAddress(emails, test.addresses, sender); got != test.want {
+				t.Errorf("replyAddress = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package signature_detector
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+type Signature struct {
+	Text  string
+	Start int
+	End   int
+	Score float64
+}
+
+type line struct {
+	text  string
+	start int
+	end   int
+}
+
+const (
+	maxSignatureLines = 15
+	minSignatureScore = 3
+	longLineLength    = 80
+)
+
+var (
+	SignOffRegexp = regexp.MustCompile(`(?i)^[ \t>]*(?:(?:best|kind|warm)(?:est)? regards|regards|many thanks|thanks|thank you|cheers|sincerely|yours(?: truly| sincerely)?|pozdrawiam|serdecznie pozdrawiam|pozdrowienia|z poważaniem|z wyrazami szacunku|dziękuję|dzięki)[ \t,!.]*$`)
+	PhoneRegexp   = regexp.MustCompile(`(?:\+|00)?\(?\d[\d \-().\/]{7,}\d`)
+	EmailRegexp   = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)
+	TitleRegexp   = regexp.MustCompile(`(?i)\b(?:ceo|cto|cfo|coo|founder|co-founder|owner|president|vice president|vp|director|manager|head of|lead|engineer|developer|consultant|specialist|assistant|partner|architect|analyst|officer|sales|marketing|account|prezes|dyrektor|kierownik|specjalista|specjalistka|inżynier|konsultant|asystent|asystentka|właściciel|właścicielka|wspólnik|handlowiec|doradca)\b`)
+
+	delimiterRegexp = regexp.MustCompile(`^--[ \t]*$`)
+	urlRegexp       = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+|\b[a-z0-9\-]+\.(?:com|pl|org|net|io|eu|de|co\.uk)\b`)
+)
+
+func Detect(text string) *Signature {
+	lines := splitLines(text)
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1].text) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	first := max(len(lines)-maxSignatureLines, 0)
+	for i := len(lines) - 1; i > 0 && i >= first; i-- {
+		if delimiterRegexp.MatchString(lines[i-1].text) {
+			return newSignature(text, lines[i:], scoreBlock(lines[i:])+3)
+		}
+	}
+	var best *Signature
+	for i := len(lines) - 1; i >= first; i-- {
+		if strings.TrimSpace(lines[i].text) == "" {
+			continue
+		}
+		if score := scoreBlock(lines[i:]); best == nil || score > best.Score {
+			best = newSignature(text, lines[i:], score)
+		}
+	}
+	if best == nil || best.Score < minSignatureScore {
+		return nil
+	}
+	return best
+}
+
+func newSignature(text string, block []line, score float64) *Signature {
+	start, end := block[0].start, block[len(block)-1].end
+	return &Signature{
+		Text:  strings.TrimSpace(text[start:end]),
+		Start: start,
+		End:   end,
+		Score: score,
+	}
+}
+
+func scoreBlock(block []line) float64 {
+	var score float64
+	var phone, email, url, title bool
+	nonEmpty := 0
+	for i, l := range block {
+		text := strings.TrimSpace(l.text)
+		if text == "" {
+			continue
+		}
+		nonEmpty++
+		if i == 0 && SignOffRegexp.MatchString(text) {
+			score += 2
+		}
+		linePhone := PhoneRegexp.MatchString(text)
+		lineEmail := EmailRegexp.MatchString(text)
+		lineUrl := urlRegexp.MatchString(text)
+		lineTitle := TitleRegexp.MatchString(text)
+		phone, email, url, title = phone || linePhone, email || lineEmail, url || lineUrl, title || lineTitle
+		switch {
+		case len(text) > longLineLength:
+			score -= 2
+		case linePhone || lineEmail || lineUrl || lineTitle || isNameLine(text):
+			score += 0.5
+		default:
+			score -= 0.25
+		}
+		if len(strings.Fields(text)) > 5 && (strings.HasSuffix(text, ".") || strings.HasSuffix(text, "?")) {
+			score--
+		}
+	}
+	if phone {
+		score += 2
+	}
+	if email {
+		score += 2
+	}
+	if url {
+		score++
+	}
+	if title {
+		score += 1.5
+	}
+	if nonEmpty > 8 {
+		score -= 0.5 * float64(nonEmpty-8)
+	}
+	return score
+}
+
+func isNameLine(text string) bool {
+	words := strings.Fields(text)
+	if len(words) < 2 || len(words) > 3 {
+		return false
+	}
+	for _, word := range words {
+		r := []rune(word)
+		if !unicode.IsUpper(r[0]) || strings.ContainsAny(word, "@:/0123456789") {
+			return false
+		}
+	}
+	return true
+}
+
+func splitLines(text string) []line {
+	var lines []line
+	start := 0
+	for start <= len(text) {
+		end := strings.IndexByte(text[start:], '\n')
+		if end < 0 {
+			lines = append(lines, line{text: text[start:], start: start, end: len(text)})
+			break
+		}
+		lines = append(lines, line{text: text[start : start+end], start: start, end: start + end})
+		start += end + 1
+	}
+	return lines
+}
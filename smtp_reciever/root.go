@@ -0,0 +1,312 @@
+package smtp_reciever
+
+import (
+	"MailContactUtilty/helper"
+	"MailContactUtilty/mail_parser"
+	"MailContactUtilty/mail_source"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-smtp"
+	"google.golang.org/api/gmail/v1"
+)
+
+type SmtpReciever struct {
+	Email    string
+	config   SmtpRecieverConfig
+	trusted  []netip.Prefix
+	messages map[string]*storedMessage
+	mu       sync.Mutex
+}
+
+type SmtpRecieverConfig struct {
+	Addr            string
+	Domain          string
+	LMTP            bool
+	MaxMessageBytes int64
+	TrustedNetworks []string
+	Relay           mail_source.SmtpRelay
+}
+
+type storedMessage struct {
+	message    *gmail.Message
+	receivedAt time.Time
+}
+
+const (
+	defaultMaxMessageBytes = 25 << 20
+	messageTTL             = time.Hour
+	shutdownTimeout        = 5 * time.Second
+)
+
+var defaultTrustedNetworks = []string{"127.0.0.0/8", "::1/128"}
+
+func NewSmtpReciever(email string, config SmtpRecieverConfig) (*SmtpReciever, error) {
+	if config.Addr == "" {
+		return nil, fmt.Errorf("smtp listen address is not set")
+	}
+	if config.Domain == "" {
+		config.Domain = "localhost"
+	}
+	if config.MaxMessageBytes <= 0 {
+		config.MaxMessageBytes = defaultMaxMessageBytes
+	}
+	if len(config.TrustedNetworks) == 0 {
+		config.TrustedNetworks = defaultTrustedNetworks
+	}
+	trusted, err := parseNetworks(config.TrustedNetworks)
+	if err != nil {
+		return nil, err
+	}
+	return &SmtpReciever{
+		Email:    email,
+		config:   config,
+		trusted:  trusted,
+		messages: map[string]*storedMessage{},
+	}, nil
+}
+
+func parseNetworks(networks []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(networks))
+	for _, network := range networks {
+		if prefix, err := netip.ParsePrefix(network); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted network: %s", network)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+func (sr *SmtpReciever) trustedClient(remote net.Addr) bool {
+	switch addr := remote.(type) {
+	case *net.UnixAddr:
+		return true
+	case *net.TCPAddr:
+		ip, ok := netip.AddrFromSlice(addr.IP)
+		if !ok {
+			return false
+		}
+		ip = ip.Unmap()
+		return slices.ContainsFunc(sr.trusted, func(prefix netip.Prefix) bool {
+			return prefix.Contains(ip)
+		})
+	}
+	return false
+}
+
+func (sr *SmtpReciever) ListenForEmails(ctx context.Context, target chan<- *mail_source.Delivery) error {
+	server := smtp.NewServer(smtp.BackendFunc(func(c *smtp.Conn) (smtp.Session, error) {
+		if remote := c.Conn().RemoteAddr(); !sr.trustedClient(remote) {
+			log.Printf("Rejecting SMTP client %s, it is not a trusted relay", remote)
+			return nil, &smtp.SMTPError{
+				Code:         554,
+				EnhancedCode: smtp.EnhancedCode{5, 7, 1},
+				Message:      "Client host rejected",
+			}
+		}
+		return &session{ctx: ctx, reciever: sr, target: target}, nil
+	}))
+	server.Addr = sr.config.Addr
+	server.Domain = sr.config.Domain
+	server.LMTP = sr.config.LMTP
+	server.MaxMessageBytes = sr.config.MaxMessageBytes
+	server.MaxRecipients = 10
+	server.ReadTimeout = time.Minute
+	server.WriteTimeout = time.Minute
+	server.Network = "tcp"
+	if strings.HasPrefix(sr.config.Addr, "/") {
+		server.Network = "unix"
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		protocol := "SMTP"
+		if sr.config.LMTP {
+			protocol = "LMTP"
+		}
+		log.Printf("Starting %s listener on %s...", protocol, sr.config.Addr)
+		errChan <- server.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("SMTP shutdown error: %v", err)
+		}
+		return ctx.Err()
+	case err := <-errChan:
+		return fmt.Errorf("smtp listener failed: %w", err)
+	}
+}
+
+func (sr *SmtpReciever) store(raw []byte) (*gmail.Message, error) {
+	id, err := newId()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := mail_parser.ParseRaw(id, raw)
+	if err != nil {
+		return nil, err
+	}
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	for storedId, stored := range sr.messages {
+		if time.Since(stored.receivedAt) > messageTTL {
+			delete(sr.messages, storedId)
+		}
+	}
+	sr.messages[id] = &storedMessage{message: msg, receivedAt: time.Now()}
+	return msg, nil
+}
+
+func (sr *SmtpReciever) GetMessage(ctx context.Context, id string) (*gmail.Message, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	stored, ok := sr.messages[id]
+	if !ok {
+		return nil, fmt.Errorf("message %s not found", id)
+	}
+	return stored.message, nil
+}
+
+func (sr *SmtpReciever) GetAttachment(ctx context.Context, messageId, attachmentId string) (*gmail.MessagePartBody, error) {
+	msg, err := sr.GetMessage(ctx, messageId)
+	if err != nil {
+		return nil, err
+	}
+	body := mail_parser.FindAttachment(msg, attachmentId)
+	if body == nil {
+		return nil, fmt.Errorf("attachment %s not found in message %s", attachmentId, messageId)
+	}
+	return body, nil
+}
+
+func (sr *SmtpReciever) MarkProcessed(ctx context.Context, id string) error {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	delete(sr.messages, id)
+	return nil
+}
+
+func (sr *SmtpReciever) Reply(ctx context.Context, id string, contacts []*helper.Contact, originalMsg *gmail.Message, sender string) error {
+	address, err := mail_parser.ParseAddress(sender)
+	if err != nil {
+		return fmt.Errorf("invalid reply address: %v", err)
+	}
+	if err := sr.config.Relay.Send(sr.Email, []string{address.Address}, mail_source.BuildReply(sr.Email, contacts, originalMsg, sender)); err != nil {
+		return fmt.Errorf("unable to send reply: %v", err)
+	}
+	return nil
+}
+
+type session struct {
+	ctx      context.Context
+	reciever *SmtpReciever
+	target   chan<- *mail_source.Delivery
+	from     string
+}
+
+func (s *session) Reset() {
+	s.from = ""
+}
+
+func (s *session) Logout() error {
+	return nil
+}
+
+func (s *session) Mail(from string, opts *smtp.MailOptions) error {
+	s.from = from
+	return nil
+}
+
+func (s *session) Rcpt(to string, opts *smtp.RcptOptions) error {
+	if !mail_parser.SameAddress(to, s.reciever.Email) {
+		return &smtp.SMTPError{
+			Code:         550,
+			EnhancedCode: smtp.EnhancedCode{5, 1, 1},
+			Message:      "Unknown recipient",
+		}
+	}
+	return nil
+}
+
+func (s *session) Data(r io.Reader) error {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	msg, err := s.reciever.store(raw)
+	if err != nil {
+		log.Printf("Unable to parse message from %s: %v", s.from, err)
+		return &smtp.SMTPError{
+			Code:         554,
+			EnhancedCode: smtp.EnhancedCode{5, 6, 0},
+			Message:      "Unable to parse message",
+		}
+	}
+
+	log.Printf("New email received - Subject: %s, From: %s",
+		getHeader(msg.Payload.Headers, "Subject"),
+		getHeader(msg.Payload.Headers, "From"))
+
+	err = mail_source.Deliver(s.ctx, s.target, msg)
+	s.reciever.MarkProcessed(s.ctx, msg.Id)
+	if s.ctx.Err() != nil {
+		return &smtp.SMTPError{
+			Code:         421,
+			EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+			Message:      "Service shutting down",
+		}
+	}
+	if err != nil && !mail_source.Retryable(err) {
+		log.Printf("Message %s cannot be processed, rejecting it: %v", msg.Id, err)
+		return &smtp.SMTPError{
+			Code:         554,
+			EnhancedCode: smtp.EnhancedCode{5, 3, 0},
+			Message:      "Message cannot be processed",
+		}
+	}
+	if err != nil {
+		log.Printf("Message %s not processed, asking sender to retry: %v", msg.Id, err)
+		return &smtp.SMTPError{
+			Code:         451,
+			EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+			Message:      "Message not processed, try again later",
+		}
+	}
+	return nil
+}
+
+func newId() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func getHeader(headers []*gmail.MessagePartHeader, name string) string {
+	for _, header := range headers {
+		if header.Name == name {
+			return header.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,178 @@
+package smtp_reciever
+
+import (
+	"MailContactUtilty/mail_source"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testMailbox = "contacts@example.com"
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func startReciever(t *testing.T, config SmtpRecieverConfig) (string, chan *mail_source.Delivery) {
+	t.Helper()
+	if config.Addr == "" {
+		config.Addr = freeAddr(t)
+	}
+	reciever, err := NewSmtpReciever(testMailbox, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	target := make(chan *mail_source.Delivery)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reciever.ListenForEmails(ctx, target)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	network := "tcp"
+	if filepath.IsAbs(config.Addr) {
+		network = "unix"
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		conn, err := net.Dial(network, config.Addr)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("listener did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return config.Addr, target
+}
+
+func settleNext(target chan *mail_source.Delivery, err error) chan string {
+	subjects := make(chan string, 1)
+	go func() {
+		delivery := <-target
+		subjects <- getHeader(delivery.Message.Payload.Headers, "Subject")
+		delivery.Settle(err)
+	}()
+	return subjects
+}
+
+func sendMail(addr, to, subject string) error {
+	client, err := smtp.Dial(addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Hello("mta.example.com"); err != nil {
+		return err
+	}
+	if err := client.Mail("jan@example.com"); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "From: Jan Kowalski <jan@example.com>\r\nTo: %s\r\nSubject: %s\r\n\r\nHi,\r\nJan\r\n", to, subject)
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func responseCode(err error) int {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code
+	}
+	return 0
+}
+
+func TestSmtpReciever(t *testing.T) {
+	addr, target := startReciever(t, SmtpRecieverConfig{})
+
+	subjects := settleNext(target, nil)
+	if err := sendMail(addr, testMailbox, "Accepted"); err != nil {
+		t.Fatalf("sending mail: %v", err)
+	}
+	if subject := <-subjects; subject != "Accepted" {
+		t.Errorf("delivered subject %q", subject)
+	}
+
+	settleNext(target, context.DeadlineExceeded)
+	if err := sendMail(addr, testMailbox, "Retry"); responseCode(err) != 451 {
+		t.Errorf("failed delivery returned %v, want 451", err)
+	}
+
+	settleNext(target, errors.New("invalid contact response"))
+	if err := sendMail(addr, testMailbox, "Rejected"); responseCode(err) != 554 {
+		t.Errorf("permanent failure returned %v, want 554", err)
+	}
+
+	if err := sendMail(addr, "someone@example.com", "Unknown"); responseCode(err) != 550 {
+		t.Errorf("unknown recipient returned %v, want 550", err)
+	}
+}
+
+func TestSmtpRecieverRejectsUntrustedClients(t *testing.T) {
+	addr, _ := startReciever(t, SmtpRecieverConfig{TrustedNetworks: []string{"10.0.0.0/8", "192.168.1.10"}})
+	if err := sendMail(addr, testMailbox, "Untrusted"); responseCode(err) != 554 {
+		t.Errorf("untrusted client returned %v, want 554", err)
+	}
+}
+
+func TestSmtpRecieverUnixSocket(t *testing.T) {
+	addr, target := startReciever(t, SmtpRecieverConfig{Addr: filepath.Join(t.TempDir(), "smtp.sock")})
+	conn, err := net.Dial("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := smtp.NewClient(conn, "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	subjects := settleNext(target, nil)
+	if err := client.Mail("jan@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Rcpt(testMailbox); err != nil {
+		t.Fatal(err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(w, "From: jan@example.com\r\nSubject: Socket\r\n\r\nHi\r\n")
+	if err := w.Close(); err != nil {
+		t.Fatalf("sending over the unix socket: %v", err)
+	}
+	if subject := <-subjects; subject != "Socket" {
+		t.Errorf("delivered subject %q", subject)
+	}
+}
+
+func TestNewSmtpRecieverRejectsInvalidNetworks(t *testing.T) {
+	if _, err := NewSmtpReciever(testMailbox, SmtpRecieverConfig{Addr: ":2525", TrustedNetworks: []string{"not-a-network"}}); err == nil {
+		t.Error("NewSmtpReciever accepted an invalid trusted network")
+	}
+}
@@ -0,0 +1,250 @@
+package vcard_parser
+
+import (
+	"MailContactUtilty/helper"
+	"MailContactUtilty/mail_parser"
+	"bytes"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"slices"
+	"strings"
+)
+
+type property struct {
+	name   string
+	params map[string][]string
+	value  string
+}
+
+func Parse(data []byte) ([]*helper.Contact, error) {
+	var contacts []*helper.Contact
+	var card []property
+	inCard := false
+	for _, line := range unfold(string(data)) {
+		prop, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VCARD"):
+			inCard = true
+			card = nil
+		case prop.name == "END" && strings.EqualFold(prop.value, "VCARD"):
+			if inCard {
+				if contact := toContact(card); contact != nil {
+					contacts = append(contacts, contact)
+				}
+			}
+			inCard = false
+		case inCard:
+			card = append(card, prop)
+		}
+	}
+	if len(contacts) == 0 {
+		return nil, fmt.Errorf("no vcards found")
+	}
+	return contacts, nil
+}
+
+func unfold(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	var lines []string
+	for _, line := range strings.Split(data, "\n") {
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if len(lines) > 0 && strings.HasSuffix(lines[len(lines)-1], "=") && isQuotedPrintable(lines[len(lines)-1]) {
+			lines[len(lines)-1] = lines[len(lines)-1] + "\n" + line
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func isQuotedPrintable(line string) bool {
+	head, _, _ := strings.Cut(line, ":")
+	return strings.Contains(strings.ToUpper(head), "QUOTED-PRINTABLE")
+}
+
+func parseLine(line string) (property, bool) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return property{}, false
+	}
+	segments := strings.Split(head, ";")
+	name := strings.ToUpper(segments[0])
+	if _, after, found := strings.Cut(name, "."); found {
+		name = after
+	}
+	prop := property{name: name, params: map[string][]string{}}
+	for _, segment := range segments[1:] {
+		key, val, found := strings.Cut(segment, "=")
+		if !found {
+			prop.params["TYPE"] = append(prop.params["TYPE"], strings.ToUpper(segment))
+			continue
+		}
+		key = strings.ToUpper(key)
+		for _, v := range strings.Split(strings.Trim(val, `"`), ",") {
+			prop.params[key] = append(prop.params[key], strings.ToUpper(v))
+		}
+	}
+	prop.value = decodeValue(prop, value)
+	return prop, true
+}
+
+func decodeValue(prop property, value string) string {
+	for _, encoding := range prop.params["ENCODING"] {
+		if encoding == "QUOTED-PRINTABLE" {
+			decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(strings.ReplaceAll(value, "=\n", ""))))
+			if err == nil {
+				value = string(decoded)
+			}
+		}
+	}
+	if charsets := prop.params["CHARSET"]; len(charsets) > 0 {
+		if decoded, err := mail_parser.DecodeCharset([]byte(value), "text/plain; charset="+charsets[0]); err == nil {
+			value = decoded
+		}
+	}
+	return value
+}
+
+func splitValue(value string) []string {
+	var parts []string
+	var current bytes.Buffer
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			switch r {
+			case 'n', 'N':
+				current.WriteRune('\n')
+			default:
+				current.WriteRune(r)
+			}
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ';':
+			parts = append(parts, strings.TrimSpace(current.String()))
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(parts, strings.TrimSpace(current.String()))
+}
+
+func unescape(value string) string {
+	return strings.Join(splitValue(strings.ReplaceAll(value, ";", `\;`)), "")
+}
+
+func preferred(props []property) *property {
+	if len(props) == 0 {
+		return nil
+	}
+	for i, prop := range props {
+		for _, t := range prop.params["TYPE"] {
+			if t == "PREF" {
+				return &props[i]
+			}
+		}
+		if len(prop.params["PREF"]) > 0 && prop.params["PREF"][0] == "1" {
+			return &props[i]
+		}
+	}
+	return &props[0]
+}
+
+func toContact(card []property) *helper.Contact {
+	byName := map[string][]property{}
+	for _, prop := range card {
+		byName[prop.name] = append(byName[prop.name], prop)
+	}
+	contact := &helper.Contact{}
+	if n := preferred(byName["N"]); n != nil {
+		parts := splitValue(n.value)
+		contact.Surname = parts[0]
+		if len(parts) > 2 {
+			parts = parts[:3]
+		}
+		contact.Name = strings.Join(filterEmpty(parts[1:]), " ")
+	}
+	if contact.Name == "" && contact.Surname == "" {
+		if fn := preferred(byName["FN"]); fn != nil {
+			fields := strings.Fields(unescape(fn.value))
+			if len(fields) > 0 {
+				contact.Name = strings.Join(fields[:max(len(fields)-1, 1)], " ")
+			}
+			if len(fields) > 1 {
+				contact.Surname = fields[len(fields)-1]
+			}
+		}
+	}
+	if email := preferred(byName["EMAIL"]); email != nil {
+		contact.Email = strings.TrimPrefix(unescape(email.value), "mailto:")
+	}
+	if tel := preferred(byName["TEL"]); tel != nil {
+		contact.Phone = strings.TrimPrefix(unescape(tel.value), "tel:")
+	}
+	for _, email := range byName["EMAIL"] {
+		contact.Emails = append(contact.Emails, helper.TypedValue{Value: strings.TrimPrefix(unescape(email.value), "mailto:"), Type: valueType(email)})
+	}
+	for _, tel := range byName["TEL"] {
+		contact.Phones = append(contact.Phones, helper.TypedValue{Value: strings.TrimPrefix(unescape(tel.value), "tel:"), Type: valueType(tel)})
+	}
+	if org := preferred(byName["ORG"]); org != nil {
+		parts := splitValue(org.value)
+		contact.Organization = parts[0]
+		contact.Department = strings.Join(filterEmpty(parts[1:]), ", ")
+	}
+	if title := preferred(byName["TITLE"]); title != nil {
+		contact.Title = unescape(title.value)
+	}
+	if adr := preferred(byName["ADR"]); adr != nil {
+		contact.Address = strings.Join(filterEmpty(splitValue(adr.value)), ", ")
+	}
+	for _, url := range byName["URL"] {
+		value := unescape(url.value)
+		switch {
+		case strings.Contains(strings.ToLower(value), "linkedin.com/"):
+			if contact.LinkedIn == "" {
+				contact.LinkedIn = value
+			}
+		case contact.Website == "":
+			contact.Website = value
+		}
+	}
+	if contact.IsEmpty() {
+		return nil
+	}
+	return contact
+}
+
+func valueType(prop property) string {
+	types := prop.params["TYPE"]
+	switch {
+	case slices.Contains(types, "FAX"):
+		return helper.TypeFax
+	case slices.Contains(types, "CELL"), slices.Contains(types, "MOBILE"):
+		return helper.TypeMobile
+	case slices.Contains(types, "WORK"):
+		return helper.TypeWork
+	case slices.Contains(types, "HOME"):
+		return helper.TypeHome
+	}
+	return helper.TypeOther
+}
+
+func filterEmpty(values []string) []string {
+	filtered := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			filtered = append(filtered, value)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,123 @@
+package vcard_parser
+
+import (
+	"MailContactUtilty/helper"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		file string
+		want []helper.Contact
+	}{
+		{
+			file: "vcard21.vcf",
+			want: []helper.Contact{{
+				Name:         "Łukasz",
+				Surname:      "Żółkiewski",
+				Email:        "lukasz@acme.pl",
+				Phone:        "+48 600 100 200",
+				Organization: "ACME Spółka Akcyjna",
+				Department:   "Sprzedaż",
+				Title:        "Kierownik",
+				Address:      "ul. Świętokrzyska 12, Warszawa, 00-001, Polska",
+				Emails:       []helper.TypedValue{{Value: "lukasz@acme.pl", Type: helper.TypeOther}},
+				Phones: []helper.TypedValue{
+					{Value: "+48 600 100 200", Type: helper.TypeMobile},
+					{Value: "+48 22 555 01 09", Type: helper.TypeFax},
+				},
+			}},
+		},
+		{
+			file: "vcard30.vcf",
+			want: []helper.Contact{{
+				Name:         "Anna Maria",
+				Surname:      "Nowak",
+				Email:        "anna@example.com",
+				Phone:        "+48 501 222 333",
+				Organization: "ACME Sp. z o.o.",
+				Department:   "Finance",
+				Title:        "Dyrektor finansowy, CFO",
+				Website:      "https://acme.pl",
+				LinkedIn:     "https://www.linkedin.com/in/anna-nowak-7b1c2d",
+				Emails: []helper.TypedValue{
+					{Value: "anna.nowak@acme.pl", Type: helper.TypeWork},
+					{Value: "anna@example.com", Type: helper.TypeHome},
+				},
+				Phones: []helper.TypedValue{
+					{Value: "+48 501 222 333", Type: helper.TypeMobile},
+					{Value: "+48 22 555 01 02", Type: helper.TypeWork},
+				},
+			}},
+		},
+		{
+			file: "vcard40.vcf",
+			want: []helper.Contact{{
+				Name:    "Piotr Jan",
+				Surname: "Wiśniewski",
+				Email:   "piotr@studio.pl",
+				Phone:   "+48-22-111-22-33",
+				Address: "ul. Długa 5, Kraków, 31-147, Polska",
+				Website: "https://studio.pl",
+				Emails:  []helper.TypedValue{{Value: "piotr@studio.pl", Type: helper.TypeWork}},
+				Phones: []helper.TypedValue{
+					{Value: "+48-501-222-333", Type: helper.TypeMobile},
+					{Value: "+48-22-111-22-33", Type: helper.TypeHome},
+				},
+			}},
+		},
+		{
+			file: "several.vcf",
+			want: []helper.Contact{
+				{
+					Name:    "Jan",
+					Surname: "Kowalski",
+					Email:   "jan@example.com",
+					Emails:  []helper.TypedValue{{Value: "jan@example.com", Type: helper.TypeOther}},
+				},
+				{
+					Name:   "Marta",
+					Phone:  "+48 12 345 67 89",
+					Phones: []helper.TypedValue{{Value: "+48 12 345 67 89", Type: helper.TypeHome}},
+				},
+				{
+					Name:         "Biuro",
+					Email:        "biuro@lex.pl",
+					Organization: "Biuro Rachunkowe Lex",
+					Emails:       []helper.TypedValue{{Value: "biuro@lex.pl", Type: helper.TypeOther}},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			contacts, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if len(contacts) != len(test.want) {
+				t.Fatalf("parsed %d contacts, want %d: %+v", len(contacts), len(test.want), contacts)
+			}
+			for i, contact := range contacts {
+				if !reflect.DeepEqual(*contact, test.want[i]) {
+					t.Errorf("contact %d = %+v, want %+v", i, *contact, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseWithoutCards(t *testing.T) {
+	for _, data := range []string{"", "BEGIN:VCARD\r\nVERSION:3.0\r\nEND:VCARD\r\n", "N:Kowalski;Jan\r\n"} {
+		if contacts, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", data, contacts)
+		}
+	}
+}
@@ -2,7 +2,12 @@ package web_handler
 
 import (
 	"MailContactUtilty/google_auth"
+	"MailContactUtilty/mail_reciever"
+	"MailContactUtilty/pubsub_push"
+	"context"
+	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"slices"
 
@@ -68,3 +73,42 @@ func Auth(a *google_auth.Auth, credentialsPath string) http.HandlerFunc {
 		MessageScreen("Registration successful", "You have successfully registered.").Render(r.Context(), w)
 	}
 }
+
+const maxPushBodyBytes = 64 << 10
+
+func PubSubPush(v *pubsub_push.Verifier, handle func(ctx context.Context, notification mail_reciever.PubSubMessage) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		token := pubsub_push.BearerToken(r)
+		if token == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if _, err := v.Verify(r.Context(), token); err != nil {
+			log.Printf("Rejected push request: %v", err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var envelope pubsub_push.Envelope
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPushBodyBytes)).Decode(&envelope); err != nil {
+			log.Printf("Unable to decode push envelope: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var notification mail_reciever.PubSubMessage
+		if err := json.Unmarshal(envelope.Message.Data, &notification); err != nil {
+			log.Printf("Unable to unmarshal message: %v", err)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err := handle(r.Context(), notification); err != nil {
+			log.Printf("Unable to handle push notification %s: %v", envelope.Message.MessageId, err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}